@@ -0,0 +1,83 @@
+package forth
+
+import (
+	"math/big"
+	"strings"
+	"unicode/utf8"
+)
+
+// The BASE variable holds the radix used to read and print numbers.
+// Number prefixes select a radix for a single literal: $ hexadecimal,
+// # decimal and % binary, so $ff, #10 and %1010 read the same in any base.
+// Numbers which fit in a cell only as unsigned ones read as the cell with
+// their bits, so with 16 bit cells $FFFF is -1.
+
+// base returns the current number base
+func (m *Machine) base() (int, error) {
+	b := m.mem[baseAddr]
+	if b < 2 || b > 36 {
+		return 0, ErrInvalidBase
+	}
+	return b, nil
+}
+
+// Base returns the number base the machine reads and prints numbers in,
+// or 10 if BASE doesn't hold a valid one
+func (m *Machine) Base() int {
+	b, err := m.base()
+	if err != nil {
+		return 10
+	}
+	return b
+}
+
+// setBase makes a word storing b in BASE
+func setBase(b int) func(*Machine) error {
+	return func(m *Machine) error {
+		m.mem[baseAddr] = b
+		return nil
+	}
+}
+
+// format returns v in the current base
+func (m *Machine) format(v *big.Int) (string, error) {
+	b, err := m.base()
+	if err != nil {
+		return "", err
+	}
+	return strings.ToUpper(v.Text(b)), nil
+}
+
+// formatCell returns the cell v in the current base
+func (m *Machine) formatCell(v int) (string, error) {
+	return m.format(big.NewInt(int64(v)))
+}
+
+// parseInt parses an integer literal in base, which may be overridden by
+// a prefix. A character between single quotes, 'c', is its code.
+func parseInt(item string, base int) (*big.Int, bool) {
+	if r, size := utf8.DecodeRuneInString(item[min(1, len(item)):]); len(item) == size+2 &&
+		item[0] == '\'' && item[len(item)-1] == '\'' && r != utf8.RuneError {
+		return big.NewInt(int64(r)), true
+	}
+	if len(item) > 1 {
+		switch item[0] {
+		case '$':
+			item, base = item[1:], 16
+		case '#':
+			item, base = item[1:], 10
+		case '%':
+			item, base = item[1:], 2
+		}
+	}
+	return new(big.Int).SetString(item, base)
+}
+
+// isNumber reports if name reads as a number in decimal, so it can't be
+// the name of a word
+func isNumber(name string) bool {
+	_, integer := parseInt(name, 10)
+	_, double := parseDouble(name, 10)
+	_, float := parseFloat(name)
+	return integer || double || float
+}
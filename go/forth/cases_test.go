@@ -19,6 +19,11 @@ var parsingGroup = []testCase{
 		[]string{"1\x002\x133\n4\r5 6\t7"},
 		[]int{1, 2, 3, 4, 5, 6, 7},
 	},
+	{
+		"unicode white space separates words",
+		[]string{"  1\u00a02\u20033\u3000 "},
+		[]int{1, 2, 3},
+	},
 }
 
 var additionGroup = []testCase{
@@ -229,6 +234,937 @@ var userdefinedGroup = []testCase{
 		[]string{"foo"},
 		[]int(nil),
 	},
+	{
+		"are case-insensitive",
+		[]string{": FOO 1 ;", "foo Foo"},
+		[]int{1, 1},
+	},
+	{
+		"errors if the definition uses a non-existent word",
+		[]string{": foo bar ;"},
+		[]int(nil),
+	},
+}
+
+var bindingGroup = []testCase{
+	{
+		"can use different words with the same name",
+		[]string{": foo 5 ;", ": bar foo ;", ": foo 6 ;", "bar foo"},
+		[]int{5, 6},
+	},
+	{
+		"can define word that uses word with the same name",
+		[]string{": foo 10 ;", ": foo foo 1 + ;", "foo"},
+		[]int{11},
+	},
+	{
+		"redefining a built-in word doesn't change earlier words",
+		[]string{": foo swap ;", ": swap dup ;", "1 2 foo", "3 swap"},
+		[]int{2, 1, 3, 3},
+	},
+	{
+		"can redefine a built-in word in terms of itself",
+		[]string{": dup dup dup ;", "1 dup"},
+		[]int{1, 1, 1},
+	},
+	{
+		"self reference in a new word is an unknown word",
+		[]string{": foo foo ;"},
+		[]int(nil),
+	},
+}
+
+var comparisonGroup = []testCase{
+	{
+		"equal numbers give true",
+		[]string{"3 3 = 3 4 ="},
+		[]int{-1, 0},
+	},
+	{
+		"less than",
+		[]string{"1 2 < 2 1 < 2 2 <"},
+		[]int{-1, 0, 0},
+	},
+	{
+		"greater than",
+		[]string{"1 2 > 2 1 > 2 2 >"},
+		[]int{0, -1, 0},
+	},
+	{
+		"not equal",
+		[]string{"1 2 <> 2 2 <>"},
+		[]int{-1, 0},
+	},
+	{
+		"zero equals",
+		[]string{"0 0= 5 0="},
+		[]int{-1, 0},
+	},
+	{
+		"errors if there is only one value on the stack",
+		[]string{"1 ="},
+		[]int(nil),
+	},
+}
+
+var logicGroup = []testCase{
+	{
+		"and of flags",
+		[]string{"-1 -1 and -1 0 AND"},
+		[]int{-1, 0},
+	},
+	{
+		"or of flags",
+		[]string{"0 -1 or 0 0 OR"},
+		[]int{-1, 0},
+	},
+	{
+		"and and or are bitwise",
+		[]string{"12 10 and 12 10 or"},
+		[]int{8, 14},
+	},
+	{
+		"invert flips all bits",
+		[]string{"0 invert -1 invert 5 invert"},
+		[]int{-1, 0, -6},
+	},
+	{
+		"errors if there is nothing on the stack",
+		[]string{"invert"},
+		[]int(nil),
+	},
+}
+
+var conditionalGroup = []testCase{
+	{
+		"if runs its body on true",
+		[]string{": foo if 1 then 2 ;", "-1 foo"},
+		[]int{1, 2},
+	},
+	{
+		"if skips its body on false",
+		[]string{": foo if 1 then 2 ;", "0 foo"},
+		[]int{2},
+	},
+	{
+		"any non-zero value is true",
+		[]string{": foo IF 1 THEN ;", "7 foo"},
+		[]int{1},
+	},
+	{
+		"else branch runs on false",
+		[]string{": sign 0 < if -1 else 1 then ;", "-5 sign 5 sign"},
+		[]int{-1, 1},
+	},
+	{
+		"if else then chooses a branch",
+		[]string{": abs dup 0 < if -1 * else 0 + then ;", "-5 abs 5 abs"},
+		[]int{5, 5},
+	},
+	{
+		"nested if inside else",
+		[]string{
+			": cmp over over = if drop drop 0 else < if -1 else 1 then then ;",
+			"1 2 cmp 2 2 cmp 3 2 cmp",
+		},
+		[]int{-1, 0, 1},
+	},
+	{
+		"errors if the flag is missing",
+		[]string{": foo if 1 then ;", "foo"},
+		[]int(nil),
+	},
+	{
+		"errors if used outside a definition",
+		[]string{"1 if 2 then"},
+		[]int(nil),
+	},
+	{
+		"errors on if without then",
+		[]string{": foo if 1 ;"},
+		[]int(nil),
+	},
+	{
+		"errors on then without if",
+		[]string{": foo 1 then ;"},
+		[]int(nil),
+	},
+	{
+		"errors on else without if",
+		[]string{": foo 1 else 2 then ;"},
+		[]int(nil),
+	},
+}
+
+var loopGroup = []testCase{
+	{
+		"do loop counts from start up to limit",
+		[]string{": foo 5 0 do i loop ;", "foo"},
+		[]int{0, 1, 2, 3, 4},
+	},
+	{
+		"sums a range",
+		[]string{": sum 0 swap 1 + 1 do i + loop ;", "10 sum"},
+		[]int{55},
+	},
+	{
+		"computes factorial",
+		[]string{": fact 1 swap 1 + 1 do i * loop ;", "5 fact"},
+		[]int{120},
+	},
+	{
+		"nested loops see both indexes",
+		[]string{": foo 2 0 do 3 0 do j 10 * i + loop loop ;", "foo"},
+		[]int{0, 1, 2, 10, 11, 12},
+	},
+	{
+		"+loop counts up by step",
+		[]string{": foo 10 0 do i 3 +loop ;", "foo"},
+		[]int{0, 3, 6, 9},
+	},
+	{
+		"+loop counts down and includes the limit",
+		[]string{": foo 0 3 do i -1 +loop ;", "foo"},
+		[]int{3, 2, 1, 0},
+	},
+	{
+		"begin until runs until the flag is true",
+		[]string{": countdown begin dup 1 - dup 0 = until ;", "3 countdown"},
+		[]int{3, 2, 1, 0},
+	},
+	{
+		"begin while repeat checks the condition first",
+		[]string{": halve begin dup 1 > while 2 / repeat ;", "20 halve 1 halve"},
+		[]int{1, 1},
+	},
+	{
+		"loops can contain conditionals",
+		[]string{": evens 7 0 do i 2 / 2 * i = if i then loop ;", "evens"},
+		[]int{0, 2, 4, 6},
+	},
+	{
+		"errors if do is missing its parameters",
+		[]string{": foo 1 do i loop ;", "foo"},
+		[]int(nil),
+	},
+	{
+		"errors if i is used outside a loop",
+		[]string{"i"},
+		[]int(nil),
+	},
+	{
+		"errors if used outside a definition",
+		[]string{"3 0 do i loop"},
+		[]int(nil),
+	},
+	{
+		"errors on do without loop",
+		[]string{": foo 3 0 do i ;"},
+		[]int(nil),
+	},
+	{
+		"errors on loop without do",
+		[]string{": foo i loop ;"},
+		[]int(nil),
+	},
+	{
+		"errors on repeat without while",
+		[]string{": foo begin 1 repeat ;"},
+		[]int(nil),
+	},
+	{
+		"errors on crossed structures",
+		[]string{": foo begin 1 if until then ;"},
+		[]int(nil),
+	},
+}
+
+var returnStackGroup = []testCase{
+	{
+		">r and r> move a value to the return stack and back",
+		[]string{": foo >r 1 r> ;", "5 foo"},
+		[]int{1, 5},
+	},
+	{
+		"r@ copies the top of the return stack",
+		[]string{": foo >R R@ R@ + R> ;", "5 foo"},
+		[]int{10, 5},
+	},
+	{
+		"values on the return stack survive word calls",
+		[]string{": bar 2 * ;", ": foo >r bar r> ;", "3 4 foo"},
+		[]int{6, 4},
+	},
+	{
+		"errors if used outside a definition",
+		[]string{"1 >r"},
+		[]int(nil),
+	},
+	{
+		"errors if there is nothing on the stack",
+		[]string{": foo >r ;", "foo"},
+		[]int(nil),
+	},
+	{
+		"errors if the return stack has no value of the word",
+		[]string{": foo r> ;", "foo"},
+		[]int(nil),
+	},
+	{
+		"errors if a value is left on the return stack",
+		[]string{": foo 1 >r ;", "foo"},
+		[]int(nil),
+	},
+	{
+		"errors if the return address is replaced before a failure",
+		[]string{": f r> drop 12345 >r 1 0 / ; f"},
+		[]int(nil),
+	},
+	{
+		"errors if the return address is replaced",
+		[]string{": f r> drop 99 >r drop ;", "f"},
+		[]int(nil),
+	},
+	{
+		"errors if the return address is replaced by a negative one",
+		[]string{": f r> drop -7 >r 1 0 / ;", "f"},
+		[]int(nil),
+	},
+	{
+		"errors if a return address is forged at the top level",
+		[]string{": f r> drop 99 >r ;", "1 f"},
+		[]int(nil),
+	},
+}
+
+var definitionErrorGroup = []testCase{
+	{
+		"division by zero inside a word",
+		[]string{": foo 1 0 / ;", "foo"},
+		[]int(nil),
+	},
+	{
+		"stack underflow inside a word",
+		[]string{": foo + ;", "1 foo"},
+		[]int(nil),
+	},
+	{
+		"error in a nested word",
+		[]string{": bar drop drop ;", ": foo 1 bar ;", "foo"},
+		[]int(nil),
+	},
+	{
+		"error after the word returned",
+		[]string{": foo 1 ;", "foo + +"},
+		[]int(nil),
+	},
+	{
+		"error inside a loop of a word",
+		[]string{": foo 3 0 do 10 i / drop loop ;", "foo"},
+		[]int(nil),
+	},
+	{
+		"error in a conditional branch",
+		[]string{": foo if drop then ;", "-1 foo"},
+		[]int(nil),
+	},
+}
+
+var definitionPlacementGroup = []testCase{
+	{
+		"definition after values",
+		[]string{"1 2 : sq dup * ; sq"},
+		[]int{1, 4},
+	},
+	{
+		"several definitions in one statement",
+		[]string{": a 1 ; : b 2 ;", "a b"},
+		[]int{1, 2},
+	},
+	{
+		"words can be used right after their definition",
+		[]string{": a 1 ; a : b a a + ; b"},
+		[]int{1, 2},
+	},
+	{
+		"values before a definition are pushed before it is compiled",
+		[]string{"3 : a 1 ; 4 a"},
+		[]int{3, 4, 1},
+	},
+	{
+		"empty definitions are allowed",
+		[]string{": nop ; 1 nop"},
+		[]int{1},
+	},
+	{
+		"blanks around the statement are ignored",
+		[]string{"  1 2  ", " "},
+		[]int{1, 2},
+	},
+	{
+		"errors on a definition without ;",
+		[]string{"1 : foo 2"},
+		[]int(nil),
+	},
+	{
+		"errors on : without a name",
+		[]string{"1 :"},
+		[]int(nil),
+	},
+	{
+		"errors on ; without :",
+		[]string{"1 ;"},
+		[]int(nil),
+	},
+	{
+		"errors on nested definitions",
+		[]string{": foo : bar 1 ; ;"},
+		[]int(nil),
+	},
+}
+
+var memoryGroup = []testCase{
+	{
+		"variables start at zero",
+		[]string{"variable x", "x @"},
+		[]int{0},
+	},
+	{
+		"store and fetch a variable",
+		[]string{"variable x 42 x ! x @"},
+		[]int{42},
+	},
+	{
+		"+! adds to a variable",
+		[]string{"variable x 40 x ! 2 x +! x @"},
+		[]int{42},
+	},
+	{
+		"variables are separate",
+		[]string{"variable a variable b 1 a ! 2 b ! a @ b @"},
+		[]int{1, 2},
+	},
+	{
+		"variables in definitions",
+		[]string{"variable count", ": bump 1 count +! ;", "bump bump bump count @"},
+		[]int{3},
+	},
+	{
+		"constants push their value",
+		[]string{"10 constant ten", ": twenty ten 2 * ;", "ten twenty"},
+		[]int{10, 20},
+	},
+	{
+		"constants are case-insensitive",
+		[]string{"7 CONSTANT Seven seven"},
+		[]int{7},
+	},
+	{
+		"values push their value and change with to",
+		[]string{"5 value v", "v 6 to v v"},
+		[]int{5, 6},
+	},
+	{
+		"to inside a definition",
+		[]string{"0 value total", ": add total + to total ;", "3 add 4 add total"},
+		[]int{7},
+	},
+	{
+		"allot reserves cells after a variable",
+		[]string{"variable arr 2 cells allot", "1 arr ! 2 arr 1 + ! 3 arr 2 + !", "arr @ arr 1 + @ arr 2 + @"},
+		[]int{1, 2, 3},
+	},
+	{
+		"here moves with allot and comma",
+		[]string{"here 3 allot here swap -", "here 7 , here swap - here 1 - @"},
+		[]int{3, 1, 7},
+	},
+	{
+		"comma compiles a table",
+		[]string{"here 10 , 20 , 30 ,", "dup 2 + @ swap @"},
+		[]int{30, 10},
+	},
+	{
+		"redefining a variable makes a new one",
+		[]string{"variable x 1 x !", ": old x ;", "variable x 2 x !", "old @ x @"},
+		[]int{1, 2},
+	},
+	{
+		"errors on fetching from address zero",
+		[]string{"0 @"},
+		[]int(nil),
+	},
+	{
+		"errors on storing past the end of data space",
+		[]string{"1 here !"},
+		[]int(nil),
+	},
+	{
+		"errors on negative addresses",
+		[]string{"-1 @"},
+		[]int(nil),
+	},
+	{
+		"errors on huge addresses",
+		[]string{"1 1000000000000 !"},
+		[]int(nil),
+	},
+	{
+		"errors on releasing more than was allotted",
+		[]string{"-10 allot"},
+		[]int(nil),
+	},
+	{
+		"errors on constant without a value",
+		[]string{"constant k"},
+		[]int(nil),
+	},
+	{
+		"errors on variable without a name",
+		[]string{"variable"},
+		[]int(nil),
+	},
+	{
+		"errors on variable named like a number",
+		[]string{"variable 5"},
+		[]int(nil),
+	},
+	{
+		"errors on to with a variable",
+		[]string{"variable x 1 to x"},
+		[]int(nil),
+	},
+	{
+		"errors on to with an unknown word",
+		[]string{"1 to nothing"},
+		[]int(nil),
+	},
+	{
+		"errors on variable inside a definition",
+		[]string{": foo variable x ;"},
+		[]int(nil),
+	},
+}
+
+var extendedArithmeticGroup = []testCase{
+	{
+		"mod gives the remainder",
+		[]string{"7 3 mod -7 3 mod"},
+		[]int{1, -1},
+	},
+	{
+		"/mod gives remainder and quotient",
+		[]string{"7 3 /mod"},
+		[]int{1, 2},
+	},
+	{
+		"negate and abs",
+		[]string{"5 negate -5 abs 5 abs"},
+		[]int{-5, 5, 5},
+	},
+	{
+		"min and max",
+		[]string{"3 -4 min 3 -4 max"},
+		[]int{-4, 3},
+	},
+	{
+		"increment and decrement",
+		[]string{"5 1+ 5 1-"},
+		[]int{6, 4},
+	},
+	{
+		"doubling and halving",
+		[]string{"5 2* -5 2/ 5 2/"},
+		[]int{10, -3, 2},
+	},
+	{
+		"shifts",
+		[]string{"1 4 lshift 256 4 rshift"},
+		[]int{16, 16},
+	},
+	{
+		"rshift is a logical shift",
+		[]string{"-1 1 rshift 0 >"},
+		[]int{-1},
+	},
+	{
+		"errors on mod by zero",
+		[]string{"1 0 mod"},
+		[]int(nil),
+	},
+	{
+		"errors on /mod by zero",
+		[]string{"1 0 /mod"},
+		[]int(nil),
+	},
+	{
+		"errors if there is nothing on the stack",
+		[]string{"negate"},
+		[]int(nil),
+	},
+}
+
+var extendedStackGroup = []testCase{
+	{
+		"rot and -rot",
+		[]string{"1 2 3 rot", "4 5 6 -rot"},
+		[]int{2, 3, 1, 6, 4, 5},
+	},
+	{
+		"nip and tuck",
+		[]string{"1 2 nip 3 4 tuck"},
+		[]int{2, 4, 3, 4},
+	},
+	{
+		"pick copies an item",
+		[]string{"1 2 3 0 pick 2 pick"},
+		[]int{1, 2, 3, 3, 2},
+	},
+	{
+		"roll moves an item",
+		[]string{"1 2 3 4 2 roll 0 roll"},
+		[]int{1, 3, 4, 2},
+	},
+	{
+		"?dup duplicates non-zero values only",
+		[]string{"0 ?dup 5 ?dup"},
+		[]int{0, 5, 5},
+	},
+	{
+		"depth counts the items",
+		[]string{"depth 7 7 depth"},
+		[]int{0, 7, 7, 3},
+	},
+	{
+		"double cell stack words",
+		[]string{"1 2 2dup", "2drop 3 4 2swap 2over"},
+		[]int{3, 4, 1, 2, 3, 4},
+	},
+	{
+		"are case-insensitive",
+		[]string{"1 2 3 Rot"},
+		[]int{2, 3, 1},
+	},
+	{
+		"errors if rot has too few items",
+		[]string{"1 2 rot"},
+		[]int(nil),
+	},
+	{
+		"errors if pick goes below the stack",
+		[]string{"1 2 2 pick"},
+		[]int(nil),
+	},
+	{
+		"errors if roll gets a negative count",
+		[]string{"1 2 -1 roll"},
+		[]int(nil),
+	},
+	{
+		"errors if 2over has too few items",
+		[]string{"1 2 3 2over"},
+		[]int(nil),
+	},
+}
+
+var doubleGroup = []testCase{
+	{
+		"double literals",
+		[]string{"123. -1. 18446744073709551616."},
+		[]int{123, 0, -1, -1, 0, 1},
+	},
+	{
+		"a double literal can't be redefined",
+		[]string{": 1. 2 ;"},
+		[]int(nil),
+	},
+	{
+		"d+ carries into the high cell",
+		[]string{"1. 2. d+ -1 0 1. d+"},
+		[]int{3, 0, 0, 1},
+	},
+	{
+		"d- borrows from the high cell",
+		[]string{"0 1 1. d-"},
+		[]int{-1, 0},
+	},
+	{
+		"dnegate",
+		[]string{"5. dnegate"},
+		[]int{-5, -1},
+	},
+	{
+		"m* gives a signed double",
+		[]string{"-3 4 m* 9223372036854775807 2 m*"},
+		[]int{-12, -1, -2, 0},
+	},
+	{
+		"um* multiplies unsigned cells",
+		[]string{"-1 2 um*"},
+		[]int{-2, 1},
+	},
+	{
+		"um/mod divides an unsigned double",
+		[]string{"0 1 2 um/mod 7. 2 um/mod"},
+		[]int{0, -9223372036854775808, 1, 3},
+	},
+	{
+		"um/mod by zero",
+		[]string{"1. 0 um/mod"},
+		[]int(nil),
+	},
+	{
+		"*/ doesn't overflow in between",
+		[]string{"9223372036854775807 4 8 */"},
+		[]int{4611686018427387903},
+	},
+	{
+		"*/mod gives remainder and quotient",
+		[]string{"7 -5 3 */mod"},
+		[]int{-2, -11},
+	},
+	{
+		"*/ by zero",
+		[]string{"1 2 0 */"},
+		[]int(nil),
+	},
+}
+
+var floatGroup = []testCase{
+	{
+		"float arithmetic",
+		[]string{"1.5e3 2e f* 0.5 f+ 1000e f- 4e f/ f>s"},
+		[]int{500},
+	},
+	{
+		"f>s rounds toward zero",
+		[]string{"2.9 f>s -2.9 f>s"},
+		[]int{2, -2},
+	},
+	{
+		"s>f converts a cell",
+		[]string{"7 s>f 2e f/ f>s"},
+		[]int{3},
+	},
+	{
+		"fsqrt",
+		[]string{"16e fsqrt f>s"},
+		[]int{4},
+	},
+	{
+		"float stack manipulation",
+		[]string{"1e 2e fswap fdup f>s fdrop f>s"},
+		[]int{1, 2},
+	},
+	{
+		"f< leaves a flag on the data stack",
+		[]string{"1e 2e f< 2e 1e f<"},
+		[]int{-1, 0},
+	},
+	{
+		"floats in definitions",
+		[]string{": half 0.5e f* ;", "9 s>f half half f>s"},
+		[]int{2},
+	},
+	{
+		"float literals can't be redefined",
+		[]string{": 1e 2 ;"},
+		[]int(nil),
+	},
+	{
+		"float stack underflow",
+		[]string{"1e f+"},
+		[]int(nil),
+	},
+	{
+		"NaN can't be converted",
+		[]string{"-1e fsqrt f>s"},
+		[]int(nil),
+	},
+}
+
+var baseGroup = []testCase{
+	{
+		"hex numbers",
+		[]string{"hex ff 10 Ab -1f"},
+		[]int{255, 16, 171, -31},
+	},
+	{
+		"binary and octal numbers",
+		[]string{"binary 1010 octal 17"},
+		[]int{10, 15},
+	},
+	{
+		"decimal restores the base",
+		[]string{"hex 10 decimal 10"},
+		[]int{16, 10},
+	},
+	{
+		"base can be set and read",
+		[]string{"16 base ! 10 base @ decimal base @"},
+		[]int{16, 16, 10},
+	},
+	{
+		"the base stays between statements",
+		[]string{"hex", "ff"},
+		[]int{255},
+	},
+	{
+		"digits beyond the base are not numbers",
+		[]string{"binary 12"},
+		[]int(nil),
+	},
+	{
+		"words come before hex numbers",
+		[]string{": add 1 + ;", "hex 10 add"},
+		[]int{17},
+	},
+	{
+		"definitions read numbers in the base of their compilation",
+		[]string{": ten 10 ;", "hex ten : sixteen 10 ; decimal sixteen"},
+		[]int{10, 16},
+	},
+	{
+		"numbers with the top bit set are their two's complement",
+		[]string{"$FFFFFFFFFFFFFFFF $8000000000000000 18446744073709551615"},
+		[]int{-1, minInt, -1},
+	},
+	{
+		"prefixes override the base",
+		[]string{"$ff #10 %1010 hex #10 $-10"},
+		[]int{255, 10, 10, 10, -16},
+	},
+	{
+		"character literals",
+		[]string{"'a' 'Z' '0' 'λ'"},
+		[]int{97, 90, 48, 955},
+	},
+	{
+		"hex doubles",
+		[]string{"hex ff. $-1."},
+		[]int{255, 0, -1, -1},
+	},
+	{
+		"no floats in hex",
+		[]string{"hex 1e"},
+		[]int{30},
+	},
+	{
+		"prefixed numbers can't be redefined",
+		[]string{": $ff 2 ;"},
+		[]int(nil),
+	},
+	{
+		"a base out of range is an error",
+		[]string{"1 base ! 10"},
+		[]int(nil),
+	},
+}
+
+var stringGroup = []testCase{
+	{
+		"s\" leaves address and length",
+		[]string{`s" hello" nip`},
+		[]int{5},
+	},
+	{
+		"the string is in memory",
+		[]string{`S" hi" drop dup c@ swap 1+ c@`},
+		[]int{'h', 'i'},
+	},
+	{
+		"c\" leaves a counted string",
+		[]string{`c" abc" count nip c" abc" c@`},
+		[]int{3, 3},
+	},
+	{
+		"strings keep spaces and words",
+		[]string{`s"  : x ; " nip`},
+		[]int{7},
+	},
+	{
+		"strings in definitions",
+		[]string{`: greeting s" hello world" ;`, "greeting nip"},
+		[]int{11},
+	},
+	{
+		"compare equal strings",
+		[]string{`s" abc" s" abc" compare`},
+		[]int{0},
+	},
+	{
+		"compare orders strings",
+		[]string{`s" abc" s" abd" compare s" b" s" abc" compare s" ab" s" abc" compare`},
+		[]int{-1, 1, -1},
+	},
+	{
+		"move and c!",
+		[]string{`s" abc" pad swap move 'x' pad 1+ c! pad 3 s" axc" compare`},
+		[]int{0},
+	},
+	{
+		"fill",
+		[]string{"pad 3 '*' fill pad c@ pad 2 + c@"},
+		[]int{42, 42},
+	},
+	{
+		"invalid string addresses",
+		[]string{"0 3 type"},
+		[]int(nil),
+	},
+	{
+		"pictured output",
+		[]string{"1234. <# # # #s #> nip"},
+		[]int{4},
+	},
+	{
+		"pictured output with hold and sign",
+		[]string{"-5 dup abs 0 <# #s '=' hold rot sign #> nip"},
+		[]int{3},
+	},
+}
+
+var commentGroup = []testCase{
+	{
+		"parenthesised comments are skipped",
+		[]string{"1 ( a comment ) 2 ( ) +"},
+		[]int{3},
+	},
+	{
+		"a comment starts with a separate (",
+		[]string{"1 (no-space) 2"},
+		[]int(nil),
+	},
+	{
+		"comments may hold any words",
+		[]string{"1 ( : ; drop . ) 2 +"},
+		[]int{3},
+	},
+	{
+		"stack effect comments in definitions",
+		[]string{": sq ( n -- n*n ) dup * ( done ) ;", "3 sq"},
+		[]int{9},
+	},
+	{
+		"comments span lines",
+		[]string{"1 ( first line\nsecond line ) 2"},
+		[]int{1, 2},
+	},
+	{
+		"an unclosed comment runs to the end of the statement",
+		[]string{"1 ( 2 3"},
+		[]int{1},
+	},
+	{
+		"backslash comments end with the line",
+		[]string{"1 \\ 2 drop\n3 \\ 4"},
+		[]int{1, 3},
+	},
+	{
+		"backslash comments in definitions",
+		[]string{": five \\ just five\n 5 ;", "five"},
+		[]int{5},
+	},
 }
 
 var testSections = []testcaseSection{
@@ -243,4 +1179,20 @@ var testSections = []testcaseSection{
 	{"swap", swapGroup},
 	{"over", overGroup},
 	{"user-defined", userdefinedGroup},
+	{"binding", bindingGroup},
+	{"comparison", comparisonGroup},
+	{"logic", logicGroup},
+	{"conditional", conditionalGroup},
+	{"loop", loopGroup},
+	{"return stack", returnStackGroup},
+	{"errors in definitions", definitionErrorGroup},
+	{"definition placement", definitionPlacementGroup},
+	{"memory", memoryGroup},
+	{"extended arithmetic", extendedArithmeticGroup},
+	{"extended stack", extendedStackGroup},
+	{"double", doubleGroup},
+	{"float", floatGroup},
+	{"base", baseGroup},
+	{"strings", stringGroup},
+	{"comments", commentGroup},
 }
@@ -0,0 +1,193 @@
+package forth
+
+import (
+	"fmt"
+	"math/bits"
+)
+
+// Overflow selects what arithmetic does with a result that doesn't fit
+// in a cell
+type Overflow int
+
+const (
+	OverflowWrap     Overflow = iota // keep the low bits, as standard Forth does
+	OverflowSaturate                 // clamp to the smallest or largest cell
+	OverflowError                    // fail with ErrOverflow
+)
+
+// setCellBits makes the cells of the machine n bits wide
+func (m *Machine) setCellBits(n int) {
+	if n != 16 && n != 32 && n != 64 || n > bits.UintSize {
+		panic(fmt.Sprintf("forth: unsupported cell width %d", n))
+	}
+	m.cellBits = n
+	m.maxCell = int(^uint(0) >> (bits.UintSize - n + 1))
+	m.minCell = -m.maxCell - 1
+}
+
+// wrap truncates v to the cell width, keeping its low bits
+func (m *Machine) wrap(v int) int {
+	shift := bits.UintSize - m.cellBits
+	return v << shift >> shift
+}
+
+// unsigned returns the bits of the cell v as an unsigned number
+func (m *Machine) unsigned(v int) uint {
+	return uint(v) & (^uint(0) >> (bits.UintSize - m.cellBits))
+}
+
+// cell fits the result v of an arithmetic operation into a cell,
+// according to the overflow mode. carry is the sign of the exact result
+// when it didn't even fit in an int, and v holds its low bits.
+func (m *Machine) cell(v, carry int) (int, error) {
+	if carry == 0 && v >= m.minCell && v <= m.maxCell {
+		return v, nil
+	}
+	switch m.overflow {
+	case OverflowSaturate:
+		if carry > 0 || carry == 0 && v > 0 {
+			return m.maxCell, nil
+		}
+		return m.minCell, nil
+	case OverflowError:
+		return 0, ErrOverflow
+	}
+	return m.wrap(v), nil
+}
+
+// arithWord makes a word out of an arithmetic operation, which returns
+// its result like the arguments of cell
+func arithWord(op func(a, b int) (int, int)) func(*Machine) error {
+	return func(m *Machine) error {
+		return binaryOp(m.valueStack, func(a, b int) (int, error) {
+			return m.cell(op(a, b))
+		})
+	}
+}
+
+// unaryArithWord makes a word out of an arithmetic operation on the top
+// of the stack
+func unaryArithWord(op func(a int) (int, int)) func(*Machine) error {
+	return func(m *Machine) error {
+		s := m.valueStack
+		if len(s.item) == 0 {
+			return ErrStackUnderflow
+		}
+		v, err := m.cell(op(s.item[len(s.item)-1]))
+		if err != nil {
+			return err
+		}
+		s.item[len(s.item)-1] = v
+		return nil
+	}
+}
+
+// add returns a + b and the carry of the sum
+func add(a, b int) (int, int) {
+	s := a + b
+	if (s > a) != (b > 0) {
+		return s, sign(b)
+	}
+	return s, 0
+}
+
+// sub returns a - b and the carry of the difference
+func sub(a, b int) (int, int) {
+	d := a - b
+	if (d < a) != (b > 0) {
+		return d, -sign(b)
+	}
+	return d, 0
+}
+
+// mul returns a * b and the carry of the product
+func mul(a, b int) (int, int) {
+	p := a * b
+	if a != 0 && (p/a != b || a == -1 && b == minInt) {
+		return p, sign(a) * sign(b)
+	}
+	return p, 0
+}
+
+// negate returns -a and its carry
+func negate(a int) (int, int) {
+	return sub(0, a)
+}
+
+// absolute returns the absolute value of a and its carry
+func absolute(a int) (int, int) {
+	if a < 0 {
+		return sub(0, a)
+	}
+	return a, 0
+}
+
+// divide implements / ( a b -- a/b ), rounding toward zero
+func (m *Machine) divide() error {
+	return binaryOp(m.valueStack, func(a, b int) (int, error) {
+		if b == 0 {
+			return 0, ErrDivisionByZero
+		}
+		return m.cell(quotient(a, b))
+	})
+}
+
+// divMod implements /MOD ( a b -- a%b a/b )
+func (m *Machine) divMod() error {
+	s := m.valueStack
+	if len(s.item) < 2 {
+		return ErrStackUnderflow
+	}
+	a, b := s.item[len(s.item)-2], s.item[len(s.item)-1]
+	if b == 0 {
+		return ErrDivisionByZero
+	}
+	q, err := m.cell(quotient(a, b))
+	if err != nil {
+		return err
+	}
+	s.item[len(s.item)-2], s.item[len(s.item)-1] = a%b, q
+	return nil
+}
+
+// quotient returns a / b and its carry, b must not be zero
+func quotient(a, b int) (int, int) {
+	if a == minInt && b == -1 {
+		return a, 1
+	}
+	return a / b, 0
+}
+
+// twoStar implements 2* ( a -- a<<1 ), a shift which never overflows
+func (m *Machine) twoStar() error {
+	return unaryOp(m.valueStack, func(a int) int { return m.wrap(a << 1) })
+}
+
+// lshift implements LSHIFT ( a u -- a<<u )
+func (m *Machine) lshift() error {
+	return binaryOp(m.valueStack, func(a, u int) (int, error) {
+		return m.wrap(a << uint(u)), nil
+	})
+}
+
+// rshift implements RSHIFT ( a u -- a>>u ), shifting zeros in from the
+// top of the cell
+func (m *Machine) rshift() error {
+	return binaryOp(m.valueStack, func(a, u int) (int, error) {
+		return m.wrap(int(m.unsigned(a) >> uint(u))), nil
+	})
+}
+
+// minInt is the smallest Go int
+const minInt = -1 << (bits.UintSize - 1)
+
+// sign returns -1, 0 or 1 for negative, zero and positive numbers
+func sign(v int) int {
+	switch {
+	case v < 0:
+		return -1
+	case v > 0:
+		return 1
+	}
+	return 0
+}
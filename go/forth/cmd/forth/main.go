@@ -0,0 +1,132 @@
+// Command forth is an interactive Forth interpreter.
+//
+// It evaluates the lines of the standard input one at a time and prints
+// the stack after each of them, or after the last line of a definition
+// spanning lines:
+//
+//	1 2 3
+//	<3> 1 2 3 ok
+//
+// Errors are reported without losing the stack or the defined words.
+// A file given as argument is evaluated as a whole instead, so definitions
+// may span lines; the stack is printed at its end, and an error stops it.
+// Besides the words of the forth package, the command BYE (quit) is
+// understood. INCLUDE and REQUIRE read
+// files relative to the current directory.
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/michalkowalik/execrism/go/forth"
+)
+
+func main() {
+	var err error
+	switch len(os.Args) {
+	case 1:
+		err = repl(os.Stdin, os.Stdout)
+	case 2:
+		var f *os.File
+		if f, err = os.Open(os.Args[1]); err != nil {
+			break
+		}
+		defer f.Close()
+		err = script(f, os.Stdout)
+	default:
+		fmt.Fprintln(os.Stderr, "usage: forth [file]")
+		os.Exit(2)
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// repl evaluates the lines read from in, writing the results and the
+// output of the words to out. A definition may continue on the next
+// lines, the status follows the line ending it. It returns at the end of
+// in or after BYE.
+func repl(in io.Reader, w io.Writer) error {
+	out := &lineWriter{w: w, atLineStart: true}
+	m := newMachine(out)
+	return m.Interpret(in, func(err error) bool {
+		if errors.Is(err, errBye) {
+			return false
+		}
+		status(out, m, err)
+		return true
+	})
+}
+
+// script evaluates the Forth source read from in, writing the output of
+// the words and the final stack to out. It returns the first failure.
+func script(in io.Reader, w io.Writer) error {
+	out := &lineWriter{w: w, atLineStart: true}
+	m := newMachine(out)
+	err := m.EvalReader(in)
+	if errors.Is(err, errBye) {
+		return nil
+	}
+	if err != nil {
+		if !out.atLineStart {
+			fmt.Fprintln(out)
+		}
+		return err
+	}
+	status(out, m, nil)
+	return nil
+}
+
+// newMachine returns a machine writing to out, which knows BYE
+func newMachine(out io.Writer) *forth.Machine {
+	m := forth.New(forth.WithOutput(out), forth.WithFS(os.DirFS(".")))
+	m.Define("BYE", func(*forth.Machine) error { return errBye })
+	return m
+}
+
+// status writes err, or the stack of m if the evaluation succeeded
+func status(out *lineWriter, m *forth.Machine, err error) {
+	// the status goes on its own line
+	if !out.atLineStart {
+		fmt.Fprintln(out)
+	}
+	if err != nil {
+		fmt.Fprintln(out, err)
+	} else {
+		fmt.Fprintln(out, formatStack(m.Stack(), m.Base()), "ok")
+	}
+}
+
+// errBye ends the evaluation of a line when BYE is run
+var errBye = errors.New("bye")
+
+// formatStack formats the stack like gforth: "<depth> bottom ... top",
+// with the items in base
+func formatStack(stack []int, base int) string {
+	var b strings.Builder
+	b.WriteString("<" + strconv.Itoa(len(stack)) + ">")
+	for _, v := range stack {
+		b.WriteString(" " + strings.ToUpper(strconv.FormatInt(int64(v), base)))
+	}
+	return b.String()
+}
+
+// lineWriter remembers if the text written so far ends a line
+type lineWriter struct {
+	w           io.Writer
+	atLineStart bool
+}
+
+func (lw *lineWriter) Write(p []byte) (int, error) {
+	if len(p) > 0 {
+		lw.atLineStart = p[len(p)-1] == '\n'
+	}
+	return lw.w.Write(p)
+}
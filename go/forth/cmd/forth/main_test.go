@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestRepl(t *testing.T) {
+	tests := []struct {
+		description string
+		input       string
+		output      string
+	}{
+		{
+			"prints the stack after every line",
+			"1 2\n3 +\n",
+			"<2> 1 2 ok\n<2> 1 5 ok\n",
+		},
+		{
+			"keeps definitions between lines",
+			": sq dup * ;\n4 sq\n",
+			"<0> ok\n<1> 16 ok\n",
+		},
+		{
+			"keeps the state after an error",
+			"1 2\n3 foo\n+\n",
+			"<2> 1 2 ok\nstatement 1, token 1: foo: unknown word\n<2> 1 5 ok\n",
+		},
+		{
+			".S prints the stack in the middle of a line",
+			"1 .s 2\n",
+			"<1> 1 \n<2> 1 2 ok\n",
+		},
+		{
+			"output words print before the status",
+			"1 2 + . cr\n.\" hi\"\n",
+			"3 \n<0> ok\nhi\n<0> ok\n",
+		},
+		{
+			"BYE stops reading",
+			"1\n2 bye 3\n4\n",
+			"<1> 1 ok\n",
+		},
+		{
+			"commands in comments and texts are not run",
+			"1 ( bye ) \\ bye\n.\"  bye words\"\n",
+			"<1> 1 ok\n bye words\n<1> 1 ok\n",
+		},
+		{
+			"the status line uses BASE",
+			"hex ff -1\ndecimal\n",
+			"<2> FF -1 ok\n<2> 255 -1 ok\n",
+		},
+		{
+			"definitions may span lines",
+			"3 : sq\n  dup * ;\nsq\n: nothing\n\n;\n",
+			"<1> 3 ok\n<1> 9 ok\n<1> 9 ok\n",
+		},
+		{
+			"the rest of a failed line is skipped",
+			"1 foo 2\n3\n",
+			"statement 0, token 1: foo: unknown word\n<2> 1 3 ok\n",
+		},
+		{
+			"empty lines are fine",
+			"\n1\n",
+			"<0> ok\n<1> 1 ok\n",
+		},
+	}
+	for _, tc := range tests {
+		var out bytes.Buffer
+		if err := repl(strings.NewReader(tc.input), &out); err != nil {
+			t.Fatalf("%s: repl returned error %v", tc.description, err)
+		}
+		if out.String() != tc.output {
+			t.Fatalf("%s: got output\n%q\nwant\n%q", tc.description, out.String(), tc.output)
+		}
+	}
+}
+
+func TestReplWords(t *testing.T) {
+	var out bytes.Buffer
+	repl(strings.NewReader(": foo 1 ; words"), &out)
+	words := strings.Fields(strings.SplitN(out.String(), "\n", 2)[0])
+	if len(words) == 0 || words[0] != "foo" {
+		t.Fatalf("WORDS printed %q, want foo first", out.String())
+	}
+}
+
+func TestScript(t *testing.T) {
+	tests := []struct {
+		description string
+		input       string
+		output      string
+		err         bool
+	}{
+		{
+			"definitions may span lines",
+			": sq ( n -- n*n )\n  dup *\n;\n3 sq\n4 sq\n",
+			"<2> 9 16 ok\n",
+			false,
+		},
+		{
+			"long lines are fine",
+			strings.Repeat("1 drop ", 20000) + "2\n",
+			"<1> 2 ok\n",
+			false,
+		},
+		{
+			"BYE stops the script",
+			"1 . bye 2 .\n",
+			"1 ",
+			false,
+		},
+		{
+			"an error stops the script",
+			"1 .\nfoo\n2 .\n",
+			"1 \n",
+			true,
+		},
+	}
+	for _, tc := range tests {
+		var out bytes.Buffer
+		err := script(strings.NewReader(tc.input), &out)
+		if (err != nil) != tc.err {
+			t.Fatalf("%s: script returned error %v", tc.description, err)
+		}
+		if out.String() != tc.output {
+			t.Fatalf("%s: got output\n%q\nwant\n%q", tc.description, out.String(), tc.output)
+		}
+	}
+}
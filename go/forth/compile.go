@@ -0,0 +1,338 @@
+package forth
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// opcode selects the operation of a compiled instruction
+type opcode uint8
+
+const (
+	opLiteral    opcode = iota // push arg on the stack
+	opBuiltin                  // run the builtin word builtins[arg]
+	opCall                     // run the user defined word words[arg]
+	opBranch                   // continue at arg
+	opBranchZero               // pop a flag, continue at arg if it is false
+	opDo                       // move loop limit and index to the return stack
+	opLoop                     // increment the loop index, continue at arg until it reaches the limit
+	opPlusLoop                 // add to the loop index, continue at arg until it crosses the limit
+	opI                        // copy the innermost loop index
+	opJ                        // copy the next outer loop index
+	opToR                      // move the top of the stack to the return stack
+	opFromR                    // move the top of the return stack to the stack
+	opRFetch                   // copy the top of the return stack to the stack
+	opPrint                    // write texts[arg] to the output
+	opGo                       // run the Go function of words[arg]
+	opFLiteral                 // push floats[arg] on the float stack, the compiler's in interpreted code
+)
+
+// returnStackWords are the builtins working on the return stack.
+// They compile to their own instructions.
+var returnStackWords = map[string]opcode{
+	"I":  opI,
+	"J":  opJ,
+	">R": opToR,
+	"R>": opFromR,
+	"R@": opRFetch,
+}
+
+// compileOnly are the builtins which can't be interpreted, because the
+// return stack belongs to the running definition
+var compileOnly = map[string]bool{
+	">R": true,
+	"R>": true,
+	"R@": true,
+}
+
+// control is a control structure still open during compilation
+type control struct {
+	word string // the word which opened it: IF, ELSE, DO, BEGIN or WHILE
+	pos  int    // position of the branch to resolve, or of the branch target
+}
+
+// instr is a single instruction of compiled Forth code
+type instr struct {
+	op  opcode
+	arg int
+}
+
+// wordKind tells how a user defined word was created
+type wordKind uint8
+
+const (
+	colonWord    wordKind = iota // : name ... ;
+	variableWord                 // VARIABLE name, param is its address
+	constantWord                 // CONSTANT name, param is its value
+	valueWord                    // VALUE name, param is its address
+	goWord                       // defined with Machine.Define, fn implements it
+	markerWord                   // MARKER name, forgets the words from itself on
+)
+
+// userWord is a compiled user defined word
+type userWord struct {
+	name   string // as written in the definition
+	kind   wordKind
+	param  int
+	code   []instr
+	fn     func(*Machine) error
+	effect string // stack effect comment of a colon definition, like "n -- n*n"
+	source string // the tokens of a colon definition between name and ;
+	mark   mark   // the dictionary before the definition
+}
+
+// compiler translates tokens into instructions, one at a time
+type compiler struct {
+	m          *Machine
+	definition bool // compiling a definition rather than interpreted code
+	code       []instr
+	pos        []int     // index of the token each instruction comes from
+	ctl        []control // control structures still open, innermost last
+	floats     []float64 // float literals of interpreted code
+}
+
+// add compiles the token item found at index in the statement
+func (c *compiler) add(item string, index int) error {
+	word := strings.ToUpper(item)
+	if controlWords[word] {
+		if !c.definition {
+			return ErrCompileOnly
+		}
+		if err := c.control(word); err != nil {
+			return err
+		}
+	} else if w, ok := c.m.dict[word]; ok {
+		// user defined words come first, as they may redefine builtins.
+		// The code of words made by the defining words never changes,
+		// so it is cheaper to copy it than to call it.
+		switch c.m.words[w].kind {
+		case colonWord:
+			c.code = append(c.code, instr{opCall, w})
+		case goWord:
+			c.code = append(c.code, instr{opGo, w})
+		case markerWord:
+			// running it would forget code which has been compiled
+			return ErrInterpretOnly
+		default:
+			c.code = append(c.code, c.m.words[w].code...)
+		}
+	} else if op, ok := returnStackWords[word]; ok {
+		if compileOnly[word] && !c.definition {
+			return ErrCompileOnly
+		}
+		c.code = append(c.code, instr{op, 0})
+	} else if b, ok := builtinIndex[word]; ok {
+		c.code = append(c.code, instr{opBuiltin, b})
+	} else if err := c.addNumber(item); err != nil {
+		return err
+	}
+	for len(c.pos) < len(c.code) {
+		c.pos = append(c.pos, index)
+	}
+	return nil
+}
+
+// addNumber compiles the number literal item, read in the current base.
+// Words come before numbers, as in hex many names are numbers too.
+func (c *compiler) addNumber(item string) error {
+	base, err := c.m.base()
+	if err != nil {
+		return err
+	}
+	// numbers fitting in a cell as unsigned ones, like masks, are taken
+	// as their bits; larger ones are handled like results
+	if i, err := strconv.Atoi(item); err == nil && base == 10 {
+		// the common case, without big numbers
+		if i < 0 || uint(i) > c.m.unsigned(-1) {
+			if i, err = c.m.cell(i, 0); err != nil {
+				return err
+			}
+		}
+		c.code = append(c.code, instr{opLiteral, c.m.wrap(i)})
+	} else if v, ok := parseInt(item, base); ok {
+		if v.Sign() < 0 || v.BitLen() > c.m.cellBits {
+			if v, err = c.m.fit(v, 1, false); err != nil {
+				return err
+			}
+		}
+		c.code = append(c.code, instr{opLiteral, c.m.cells(v, 1)[0]})
+	} else if d, ok := parseDouble(item, base); ok {
+		if d, err = c.m.fit(d, 2, false); err != nil {
+			return err
+		}
+		for _, v := range c.m.cells(d, 2) {
+			c.code = append(c.code, instr{opLiteral, v})
+		}
+	} else if f, ok := parseFloat(item); ok && base == 10 {
+		c.code = append(c.code, instr{opFLiteral, c.floatConst(f)})
+	} else {
+		return ErrUnknownWord
+	}
+	return nil
+}
+
+// known reports if word, in upper case, names a word rather than
+// a number
+func (m *Machine) known(word string) bool {
+	_, user := m.dict[word]
+	_, rs := returnStackWords[word]
+	_, builtin := builtinIndex[word]
+	return user || rs || builtin || controlWords[word]
+}
+
+// addText compiles printing a text, found at index in the statement.
+// Equal texts share their entry in the machine's texts. Interpreted texts
+// are printed right away instead.
+func (c *compiler) addText(text string, index int) {
+	i, ok := c.m.textIndex[text]
+	if !ok {
+		i = len(c.m.texts)
+		c.m.texts = append(c.m.texts, text)
+		c.m.textIndex[text] = i
+	}
+	c.code = append(c.code, instr{opPrint, i})
+	c.pos = append(c.pos, index)
+}
+
+// floatConst returns the index of the float literal f in the literals of
+// the code. Only those of definitions are kept by the machine, as long as
+// the dictionary.
+func (c *compiler) floatConst(f float64) int {
+	if !c.definition {
+		c.floats = append(c.floats, f)
+		return len(c.floats) - 1
+	}
+	return c.m.floatConst(f)
+}
+
+// floatConst returns the index of f in the machine's floats,
+// adding it if it isn't there yet
+func (m *Machine) floatConst(f float64) int {
+	bits := math.Float64bits(f)
+	i, ok := m.floatIndex[bits]
+	if !ok {
+		i = len(m.floats)
+		m.floats = append(m.floats, f)
+		m.floatIndex[bits] = i
+	}
+	return i
+}
+
+// addString compiles a string literal, found at index in the statement.
+// It leaves the address of a counted string, or the address and length
+// of the string. The strings of interpreted code are transient.
+func (c *compiler) addString(text string, counted bool, index int) error {
+	store := c.m.stringConst
+	if !c.definition {
+		store = c.m.transientString
+	}
+	addr, err := store(text)
+	if err != nil {
+		return err
+	}
+	if counted {
+		c.code = append(c.code, instr{opLiteral, addr})
+	} else {
+		c.code = append(c.code, instr{opLiteral, addr + 1}, instr{opLiteral, c.m.mem[addr]})
+	}
+	for len(c.pos) < len(c.code) {
+		c.pos = append(c.pos, index)
+	}
+	return nil
+}
+
+// addTo compiles TO name, found at index in the statement
+func (c *compiler) addTo(name string, index int) error {
+	addr, err := c.m.valueAddr(name)
+	if err != nil {
+		return err
+	}
+	c.code = append(c.code, instr{opLiteral, addr}, instr{opBuiltin, wordStore})
+	c.pos = append(c.pos, index, index)
+	return nil
+}
+
+// finish checks that all control structures have been closed
+func (c *compiler) finish() error {
+	if len(c.ctl) > 0 {
+		return fmt.Errorf("%w: unterminated %s", ErrControlStructure, c.ctl[len(c.ctl)-1].word)
+	}
+	return nil
+}
+
+// controlWords are handled by the compiler itself
+var controlWords = map[string]bool{
+	"IF": true, "ELSE": true, "THEN": true,
+	"DO": true, "LOOP": true, "+LOOP": true,
+	"BEGIN": true, "UNTIL": true, "WHILE": true, "REPEAT": true,
+}
+
+// control compiles a control structure word
+func (c *compiler) control(word string) error {
+	code, ctl := c.code, c.ctl
+	var top control
+	if len(ctl) > 0 {
+		top = ctl[len(ctl)-1]
+	}
+	unexpected := func() error {
+		return fmt.Errorf("%w: unexpected %s", ErrControlStructure, word)
+	}
+
+	switch word {
+	case "IF":
+		ctl = append(ctl, control{word, len(code)})
+		code = append(code, instr{opBranchZero, 0})
+	case "ELSE":
+		if top.word != "IF" {
+			return unexpected()
+		}
+		code = append(code, instr{opBranch, 0})
+		code[top.pos].arg = len(code)
+		ctl[len(ctl)-1] = control{word, len(code) - 1}
+	case "THEN":
+		if top.word != "IF" && top.word != "ELSE" {
+			return unexpected()
+		}
+		code[top.pos].arg = len(code)
+		ctl = ctl[:len(ctl)-1]
+	case "DO":
+		code = append(code, instr{opDo, 0})
+		ctl = append(ctl, control{word, len(code)})
+	case "LOOP", "+LOOP":
+		if top.word != "DO" {
+			return unexpected()
+		}
+		op := opLoop
+		if word == "+LOOP" {
+			op = opPlusLoop
+		}
+		code = append(code, instr{op, top.pos})
+		ctl = ctl[:len(ctl)-1]
+	case "BEGIN":
+		ctl = append(ctl, control{word, len(code)})
+	case "UNTIL":
+		if top.word != "BEGIN" {
+			return unexpected()
+		}
+		code = append(code, instr{opBranchZero, top.pos})
+		ctl = ctl[:len(ctl)-1]
+	case "WHILE":
+		if top.word != "BEGIN" {
+			return unexpected()
+		}
+		ctl = append(ctl, control{word, len(code)})
+		code = append(code, instr{opBranchZero, 0})
+	case "REPEAT":
+		if top.word != "WHILE" {
+			return unexpected()
+		}
+		begin := ctl[len(ctl)-2]
+		code = append(code, instr{opBranch, begin.pos})
+		code[top.pos].arg = len(code)
+		ctl = ctl[:len(ctl)-2]
+	}
+	c.code, c.ctl = code, ctl
+	return nil
+}
@@ -0,0 +1,57 @@
+package forth
+
+import "strings"
+
+// Define adds a word implemented in Go to the dictionary, so host
+// applications can offer their own functions to Forth code. The word is
+// case-insensitive and follows the rules of user defined words: it hides
+// builtins and earlier words of the same name, while words compiled before
+// keep using the old meaning. It survives Reset.
+//
+// fn works on the stack with Pop, Push, PopN and Peek. An error returned
+// by fn fails the evaluation; it is reported wrapped in *Error.
+func (m *Machine) Define(name string, fn func(*Machine) error) error {
+	if fn == nil || name == "" || strings.IndexFunc(name, isSeparator) >= 0 {
+		return ErrInvalidDefinition
+	}
+	if err := checkName(name); err != nil {
+		return err
+	}
+	w := userWord{name: name, kind: goWord, fn: fn, mark: m.mark()}
+	if err := m.addWord(w); err != nil {
+		return err
+	}
+	m.goWords = append(m.goWords, w)
+	return nil
+}
+
+// Pop removes the top of the stack and returns it
+func (m *Machine) Pop() (int, error) {
+	return m.valueStack.pop()
+}
+
+// Push puts v on top of the stack, truncated to the cell width
+func (m *Machine) Push(v int) {
+	m.valueStack.push(m.wrap(v))
+}
+
+// PopN removes the top n items of the stack and returns them, the deepest
+// one first. If there are fewer items, the stack is left unchanged.
+func (m *Machine) PopN(n int) ([]int, error) {
+	s := m.valueStack
+	if n < 0 || n > len(s.item) {
+		return nil, ErrStackUnderflow
+	}
+	items := append([]int{}, s.item[len(s.item)-n:]...)
+	s.item = s.item[:len(s.item)-n]
+	return items, nil
+}
+
+// Peek returns the top of the stack without removing it
+func (m *Machine) Peek() (int, error) {
+	s := m.valueStack
+	if len(s.item) == 0 {
+		return 0, ErrStackUnderflow
+	}
+	return s.item[len(s.item)-1], nil
+}
@@ -0,0 +1,198 @@
+package forth
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Definition describes a word of the dictionary
+type Definition struct {
+	Name string // as written when it was defined
+
+	// Kind is "colon", "variable", "constant", "value", "go", "marker"
+	// or "builtin"
+	Kind string
+
+	StackEffect string // comment after the name of a colon definition, like "n -- n*n"
+	Source      string // the definition as shown by SEE
+}
+
+// kindNames are the Kind of the user defined words
+var kindNames = map[wordKind]string{
+	colonWord:    "colon",
+	variableWord: "variable",
+	constantWord: "constant",
+	valueWord:    "value",
+	goWord:       "go",
+	markerWord:   "marker",
+}
+
+// mark is the state of the dictionary before a definition,
+// which FORGET and markers go back to
+type mark struct {
+	words, here, texts, floats, included int
+}
+
+// mark returns the current state of the dictionary
+func (m *Machine) mark() mark {
+	return mark{len(m.words), m.here, len(m.texts), len(m.floats), len(m.included)}
+}
+
+// Dictionary describes all words the machine knows, in the order of Words
+func (m *Machine) Dictionary() []Definition {
+	var defs []Definition
+	for i := len(m.words) - 1; i >= 0; i-- {
+		if m.dict[strings.ToUpper(m.words[i].name)] == i {
+			defs = append(defs, m.definition(i))
+		}
+	}
+
+	var special []string
+	for w := range controlWords {
+		special = append(special, w)
+	}
+	for w := range returnStackWords {
+		special = append(special, w)
+	}
+	for w := range parsingWords {
+		special = append(special, w)
+	}
+	sort.Strings(special)
+	others := []string{":", ";"}
+	for _, b := range builtins {
+		others = append(others, b.name)
+	}
+	for _, w := range append(others, special...) {
+		if _, ok := m.dict[w]; !ok {
+			defs = append(defs, Definition{Name: w, Kind: "builtin", Source: w + " is a builtin"})
+		}
+	}
+	return defs
+}
+
+// definition describes the user defined word words[i]
+func (m *Machine) definition(i int) Definition {
+	w := &m.words[i]
+	d := Definition{Name: w.name, Kind: kindNames[w.kind], StackEffect: w.effect}
+	switch w.kind {
+	case colonWord:
+		d.Source = ": " + w.name + " " + w.source + " ;"
+		if w.source == "" {
+			d.Source = ": " + w.name + " ;"
+		}
+	case variableWord:
+		d.Source = "VARIABLE " + w.name
+	case constantWord:
+		d.Source = strconv.Itoa(w.param) + " CONSTANT " + w.name
+	case valueWord:
+		d.Source = strconv.Itoa(m.mem[w.param]) + " VALUE " + w.name
+	case goWord:
+		d.Source = w.name + " is defined in Go"
+	case markerWord:
+		d.Source = "MARKER " + w.name
+	}
+	return d
+}
+
+// See returns the definition of the word name, like SEE shows it
+func (m *Machine) See(name string) (string, error) {
+	upper := strings.ToUpper(name)
+	if w, ok := m.dict[upper]; ok {
+		return m.definition(w).Source, nil
+	}
+	for _, d := range m.Dictionary() {
+		if d.Name == upper {
+			return d.Source, nil
+		}
+	}
+	return "", ErrUnknownWord
+}
+
+// Forget removes the user defined word name from the dictionary, together
+// with all the words defined after it, and releases the data space they
+// took. Older definitions of the same names become visible again.
+func (m *Machine) Forget(name string) error {
+	w, ok := m.dict[strings.ToUpper(name)]
+	if !ok {
+		return fmt.Errorf("%w: %s is not a user defined word", ErrUnknownWord, name)
+	}
+	m.restore(m.words[w].mark)
+	return nil
+}
+
+// Marker adds the word name, which forgets itself and all the words
+// defined after it when run, like Forget. Files included after it can be
+// required again.
+func (m *Machine) Marker(name string) error {
+	if err := checkName(name); err != nil {
+		return err
+	}
+	return m.addWord(userWord{name: name, kind: markerWord, mark: m.mark()})
+}
+
+// restore brings the dictionary back to the state at mk
+func (m *Machine) restore(mk mark) {
+	m.words = m.words[:mk.words]
+	clear(m.dict)
+	for i, w := range m.words {
+		m.dict[strings.ToUpper(w.name)] = i
+	}
+	m.here = min(m.here, mk.here) // ALLOT may have released more
+	m.mem = m.mem[:m.here]
+	for text, addr := range m.stringIndex {
+		if addr >= m.here {
+			delete(m.stringIndex, text)
+		}
+	}
+	m.texts = m.texts[:mk.texts]
+	for text, i := range m.textIndex {
+		if i >= mk.texts {
+			delete(m.textIndex, text)
+		}
+	}
+	m.floats = m.floats[:mk.floats]
+	for bits, i := range m.floatIndex {
+		if i >= mk.floats {
+			delete(m.floatIndex, bits)
+		}
+	}
+	for name, i := range m.included {
+		if i >= mk.included {
+			delete(m.included, name)
+		}
+	}
+}
+
+func init() {
+	builtins[builtinIndex["WORDS"]].fn = (*Machine).wordsOp
+}
+
+// wordsOp implements WORDS ( -- ), printing the names of all words
+func (m *Machine) wordsOp() error {
+	return m.write(strings.Join(m.Words(), " ") + "\n")
+}
+
+// sourceText joins the tokens of a definition, putting back the ends of
+// its texts and comments
+func sourceText(items []string) string {
+	var b strings.Builder
+	for i := 0; i < len(items); i++ {
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteString(items[i])
+		if i+1 == len(items) {
+			break
+		}
+		if items[i] == "(" {
+			i++
+			b.WriteString(" " + items[i] + ")")
+		} else if stringWords[strings.ToUpper(items[i])] {
+			i++
+			b.WriteString(" " + items[i] + `"`)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,168 @@
+package forth
+
+import (
+	"math/big"
+	"strings"
+)
+
+// A double-cell number takes two cells on the stack, the high cell on
+// top. The double and mixed precision words compute their exact results
+// with big integers and fit them into cells afterwards, so intermediate
+// results never overflow.
+
+// ubig returns the cell v as an unsigned number
+func (m *Machine) ubig(v int) *big.Int {
+	return new(big.Int).SetUint64(uint64(m.unsigned(v)))
+}
+
+// double returns the signed double made of the cells lo and hi
+func (m *Machine) double(lo, hi int) *big.Int {
+	d := big.NewInt(int64(hi))
+	return d.Lsh(d, uint(m.cellBits)).Or(d, m.ubig(lo))
+}
+
+// udouble returns the unsigned double made of the cells lo and hi
+func (m *Machine) udouble(lo, hi int) *big.Int {
+	d := m.ubig(hi)
+	return d.Lsh(d, uint(m.cellBits)).Or(d, m.ubig(lo))
+}
+
+// fit fits the exact result v into n cells, according to the overflow
+// mode. Unsigned results range from zero up.
+func (m *Machine) fit(v *big.Int, n int, unsigned bool) (*big.Int, error) {
+	limit := new(big.Int).Lsh(big.NewInt(1), uint(n*m.cellBits))
+	least := new(big.Int)
+	if !unsigned {
+		limit.Rsh(limit, 1)
+		least.Neg(limit)
+	}
+	if v.Cmp(least) >= 0 && v.Cmp(limit) < 0 {
+		return v, nil
+	}
+	switch m.overflow {
+	case OverflowSaturate:
+		if v.Sign() < 0 {
+			return least, nil
+		}
+		return limit.Sub(limit, big.NewInt(1)), nil
+	case OverflowError:
+		return nil, ErrOverflow
+	}
+	return v, nil // cells keeps the low bits
+}
+
+// cells splits v into n cells, lowest first
+func (m *Machine) cells(v *big.Int, n int) []int {
+	mask := m.ubig(-1)
+	v = new(big.Int).Set(v)
+	c := make([]int, n)
+	for i := range c {
+		c[i] = m.wrap(int(new(big.Int).And(v, mask).Uint64()))
+		v.Rsh(v, uint(m.cellBits))
+	}
+	return c
+}
+
+// doubleResult fits v into a double and returns its cells
+func (m *Machine) doubleResult(v *big.Int, unsigned bool) ([]int, error) {
+	v, err := m.fit(v, 2, unsigned)
+	if err != nil {
+		return nil, err
+	}
+	return m.cells(v, 2), nil
+}
+
+// divResult fits the quotient q into a cell and returns the cells of the
+// remainder r and q
+func (m *Machine) divResult(r, q *big.Int, unsigned bool) ([]int, error) {
+	q, err := m.fit(q, 1, unsigned)
+	if err != nil {
+		return nil, err
+	}
+	return []int{m.cells(r, 1)[0], m.cells(q, 1)[0]}, nil
+}
+
+// dPlus implements D+ ( d1 d2 -- d1+d2 )
+func dPlus(m *Machine, c []int) ([]int, error) {
+	d := m.double(c[0], c[1])
+	return m.doubleResult(d.Add(d, m.double(c[2], c[3])), false)
+}
+
+// dMinus implements D- ( d1 d2 -- d1-d2 )
+func dMinus(m *Machine, c []int) ([]int, error) {
+	d := m.double(c[0], c[1])
+	return m.doubleResult(d.Sub(d, m.double(c[2], c[3])), false)
+}
+
+// dNegate implements DNEGATE ( d -- -d )
+func dNegate(m *Machine, c []int) ([]int, error) {
+	d := m.double(c[0], c[1])
+	return m.doubleResult(d.Neg(d), false)
+}
+
+// mStar implements M* ( n1 n2 -- d ), the signed product of two cells
+func mStar(m *Machine, c []int) ([]int, error) {
+	d := big.NewInt(int64(c[0]))
+	return m.doubleResult(d.Mul(d, big.NewInt(int64(c[1]))), false)
+}
+
+// umStar implements UM* ( u1 u2 -- ud ), the unsigned product of two cells
+func umStar(m *Machine, c []int) ([]int, error) {
+	d := m.ubig(c[0])
+	return m.doubleResult(d.Mul(d, m.ubig(c[1])), true)
+}
+
+// umSlashMod implements UM/MOD ( ud u -- rem quot ), dividing an unsigned
+// double by an unsigned cell
+func umSlashMod(m *Machine, c []int) ([]int, error) {
+	if c[2] == 0 {
+		return nil, ErrDivisionByZero
+	}
+	q, r := new(big.Int).QuoRem(m.udouble(c[0], c[1]), m.ubig(c[2]), new(big.Int))
+	return m.divResult(r, q, true)
+}
+
+// starSlash implements */ ( n1 n2 n3 -- n1*n2/n3 ) with a double
+// intermediate product, rounding toward zero like /
+func starSlash(m *Machine, c []int) ([]int, error) {
+	res, err := starSlashMod(m, c)
+	if err != nil {
+		return nil, err
+	}
+	return res[1:], nil
+}
+
+// starSlashMod implements */MOD ( n1 n2 n3 -- rem quot ) with a double
+// intermediate product
+func starSlashMod(m *Machine, c []int) ([]int, error) {
+	if c[2] == 0 {
+		return nil, ErrDivisionByZero
+	}
+	p := big.NewInt(int64(c[0]))
+	p.Mul(p, big.NewInt(int64(c[1])))
+	q, r := p.QuoRem(p, big.NewInt(int64(c[2])), new(big.Int))
+	return m.divResult(r, q, false)
+}
+
+// dDot implements D. ( d -- ), printing a double
+func (m *Machine) dDot() error {
+	s := m.valueStack
+	if len(s.item) < 2 {
+		return ErrStackUnderflow
+	}
+	text, err := m.format(m.double(s.item[len(s.item)-2], s.item[len(s.item)-1]))
+	if err != nil {
+		return err
+	}
+	s.item = s.item[:len(s.item)-2]
+	return m.write(text + " ")
+}
+
+// parseDouble parses a double literal in base, which is a number
+// followed by a dot
+func parseDouble(item string, base int) (*big.Int, bool) {
+	if len(item) < 2 || !strings.HasSuffix(item, ".") {
+		return nil, false
+	}
+	return parseInt(item[:len(item)-1], base)
+}
@@ -0,0 +1,84 @@
+package forth
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Errors reported by the evaluator. They are always wrapped in *Error,
+// so use errors.Is to check for them.
+var (
+	ErrStackUnderflow       = errors.New("stack underflow")
+	ErrDivisionByZero       = errors.New("division by zero")
+	ErrUnknownWord          = errors.New("unknown word")
+	ErrInvalidDefinition    = errors.New("invalid word definition")
+	ErrCompileOnly          = errors.New("word can only be used in a definition")
+	ErrInterpretOnly        = errors.New("word can't be used in a definition")
+	ErrControlStructure     = errors.New("unbalanced control structure")
+	ErrReturnStackUnderflow = errors.New("return stack underflow")
+	ErrReturnStackOverflow  = errors.New("return stack overflow")
+	ErrReturnStackImbalance = errors.New("return stack imbalance")
+	ErrStackOverflow        = errors.New("stack overflow")
+	ErrStepLimit            = errors.New("step limit exceeded")
+	ErrDictionaryFull       = errors.New("dictionary full")
+	ErrMemoryFull           = errors.New("data space full")
+	ErrInvalidAddress       = errors.New("invalid memory address")
+	ErrOverflow             = errors.New("arithmetic overflow")
+	ErrFloatStackUnderflow  = errors.New("float stack underflow")
+	ErrInvalidBase          = errors.New("invalid number base")
+	ErrHoldOverflow         = errors.New("pictured numeric output overflow")
+	ErrStringOverflow       = errors.New("string too long for the transient buffer")
+	ErrIncludeCycle         = errors.New("file includes itself")
+
+	// ErrUnterminatedDefinition matches ErrInvalidDefinition as well
+	ErrUnterminatedDefinition = fmt.Errorf("%w: missing ;", ErrInvalidDefinition)
+)
+
+// Error describes a failed evaluation and the place where it happened
+type Error struct {
+	Statement int      // index of the statement in the source or File, counting from 0
+	Token     int      // index of the token within the statement
+	File      string   // the included file where the failure happened, if any
+	Line      int      // line of the failing token in the source or File, counting from 1
+	Column    int      // column of the token in runes, counting from 1
+	Word      string   // the word that failed
+	Trace     []string // user defined words being run, outermost first
+	Stack     []int    // copy of the stack at the moment of failure
+	Err       error    // the underlying error
+}
+
+// Error formats the error with the call trace leading to the failing word,
+// e.g. "statement 0, token 1: foo -> bar -> /: division by zero".
+// Failures in included files start with the file, line and column.
+func (e *Error) Error() string {
+	words := e.Trace
+	if e.Word != "" {
+		words = append(words[:len(words):len(words)], e.Word)
+	}
+	var b strings.Builder
+	if e.File != "" {
+		fmt.Fprintf(&b, "%s:%d:%d: ", e.File, e.Line, e.Column)
+	}
+	fmt.Fprintf(&b, "statement %d, token %d: ", e.Statement, e.Token)
+	if len(words) > 0 {
+		b.WriteString(strings.Join(words, " -> ") + ": ")
+	}
+	b.WriteString(e.Err.Error())
+	return b.String()
+}
+
+// Unwrap returns the underlying error, so errors.Is works on *Error
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// newError builds an *Error for the token at index
+func newError(items []string, index int, s *stack, err error) *Error {
+	return &Error{
+		Token: index,
+		Word:  items[index],
+		Stack: append([]int{}, s.item...),
+		Err:   err,
+	}
+}
@@ -0,0 +1,155 @@
+package forth
+
+import (
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Floating point numbers live on their own stack, apart from the cells.
+
+// FloatStack returns a copy of the float stack, bottom element first
+func (m *Machine) FloatStack() []float64 {
+	return append([]float64{}, m.floatStack...)
+}
+
+// fpush puts f on top of the float stack
+func (m *Machine) fpush(f float64) {
+	m.floatStack = append(m.floatStack, f)
+}
+
+// fpop removes the top of the float stack and returns it
+func (m *Machine) fpop() (float64, error) {
+	l := len(m.floatStack)
+	if l == 0 {
+		return 0, ErrFloatStackUnderflow
+	}
+	f := m.floatStack[l-1]
+	m.floatStack = m.floatStack[:l-1]
+	return f, nil
+}
+
+// floatWord makes a word out of a binary operation on the float stack
+func floatWord(op func(a, b float64) float64) func(*Machine) error {
+	return func(m *Machine) error {
+		fs := m.floatStack
+		if len(fs) < 2 {
+			return ErrFloatStackUnderflow
+		}
+		fs[len(fs)-2] = op(fs[len(fs)-2], fs[len(fs)-1])
+		m.floatStack = fs[:len(fs)-1]
+		return nil
+	}
+}
+
+// unaryFloatWord makes a word out of an operation on the top of the
+// float stack
+func unaryFloatWord(op func(a float64) float64) func(*Machine) error {
+	return func(m *Machine) error {
+		fs := m.floatStack
+		if len(fs) == 0 {
+			return ErrFloatStackUnderflow
+		}
+		fs[len(fs)-1] = op(fs[len(fs)-1])
+		return nil
+	}
+}
+
+// fDot implements F. ( F: f -- ), printing the top of the float stack
+func (m *Machine) fDot() error {
+	f, err := m.fpop()
+	if err != nil {
+		return err
+	}
+	return m.write(strconv.FormatFloat(f, 'g', -1, 64) + " ")
+}
+
+// fDup implements FDUP ( F: f -- f f )
+func (m *Machine) fDup() error {
+	if len(m.floatStack) == 0 {
+		return ErrFloatStackUnderflow
+	}
+	m.fpush(m.floatStack[len(m.floatStack)-1])
+	return nil
+}
+
+// fDrop implements FDROP ( F: f -- )
+func (m *Machine) fDrop() error {
+	_, err := m.fpop()
+	return err
+}
+
+// fSwap implements FSWAP ( F: a b -- b a )
+func (m *Machine) fSwap() error {
+	fs := m.floatStack
+	if len(fs) < 2 {
+		return ErrFloatStackUnderflow
+	}
+	fs[len(fs)-2], fs[len(fs)-1] = fs[len(fs)-1], fs[len(fs)-2]
+	return nil
+}
+
+// fLess implements F< ( F: a b -- ) ( -- flag )
+func (m *Machine) fLess() error {
+	fs := m.floatStack
+	if len(fs) < 2 {
+		return ErrFloatStackUnderflow
+	}
+	m.valueStack.push(flag(fs[len(fs)-2] < fs[len(fs)-1]))
+	m.floatStack = fs[:len(fs)-2]
+	return nil
+}
+
+// sToF implements S>F ( n -- ) ( F: -- f )
+func (m *Machine) sToF() error {
+	n, err := m.valueStack.pop()
+	if err != nil {
+		return err
+	}
+	m.fpush(float64(n))
+	return nil
+}
+
+// fToS implements F>S ( F: f -- ) ( -- n ), rounding toward zero.
+// Numbers out of the range of a cell are handled like arithmetic results,
+// NaN can't be converted at all.
+func (m *Machine) fToS() error {
+	fs := m.floatStack
+	if len(fs) == 0 {
+		return ErrFloatStackUnderflow
+	}
+	f := fs[len(fs)-1]
+	if math.IsNaN(f) {
+		return ErrOverflow
+	}
+	var v *big.Int
+	if math.IsInf(f, 0) {
+		// bigger than any cell, and zero in the low bits
+		v = new(big.Int).Lsh(big.NewInt(int64(math.Copysign(1, f))), uint(2*m.cellBits))
+	} else {
+		v, _ = big.NewFloat(f).Int(nil)
+	}
+	v, err := m.fit(v, 1, false)
+	if err != nil {
+		return err
+	}
+	m.floatStack = fs[:len(fs)-1]
+	m.valueStack.push(m.cells(v, 1)[0])
+	return nil
+}
+
+// parseFloat parses a float literal. It has a decimal point or an
+// exponent, so it can't be mistaken for a number or a double: 1.5, 1e3,
+// -2.5E-1, or 1e, which is 1.0.
+func parseFloat(item string) (float64, bool) {
+	if !strings.ContainsAny(item, ".eE") || strings.HasSuffix(item, ".") ||
+		strings.Trim(item, "+-.eE0123456789") != "" {
+		return 0, false
+	}
+	if strings.HasSuffix(item, "e") || strings.HasSuffix(item, "E") {
+		item += "0"
+	}
+	f, err := strconv.ParseFloat(item, 64)
+	return f, err == nil
+}
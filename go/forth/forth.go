@@ -2,9 +2,11 @@
 package forth
 
 import (
-	"errors"
-	"regexp"
-	"strconv"
+	"bufio"
+	"context"
+	"io"
+	"io/fs"
+	"math/bits"
 	"strings"
 )
 
@@ -30,7 +32,7 @@ func (s *stack) pop() (int, error) {
 	l := len(s.item)
 
 	if l == 0 {
-		return 0, errors.New("Empty Stack")
+		return 0, ErrStackUnderflow
 	}
 
 	res := s.item[l-1]
@@ -38,114 +40,237 @@ func (s *stack) pop() (int, error) {
 	return res, nil
 }
 
-var forthWords = []string{"+", "-", "*", "/", "DUP", "DROP", "SWAP", "OVER"}
+// Forth flags: true is a cell with all bits set
+const (
+	flagTrue  = -1
+	flagFalse = 0
+)
 
-// Forth is the main evaluator function
-func Forth(val []string) ([]int, error) {
-	// dictionary of user defined words:
-	userWords := make(map[string][]string)
-	valueStack := newStack()
+// Machine is a Forth interpreter. Unlike Forth it keeps the stack and the
+// user defined words between calls, so lines can be fed one at a time.
+type Machine struct {
+	valueStack  *stack
+	returnStack *stack         // return frames and loop parameters
+	words       []userWord     // compiled user defined words
+	dict        map[string]int // upper case word name -> index in words
+	statement   int
+	steps       int             // instructions run by the statement being evaluated
+	ctx         context.Context // of the statement being evaluated
+	limits      Limits
+	out         io.Writer      // destination of the output words
+	in          *bufio.Reader  // source of the input words
+	mem         []int          // data space, addressed by cell
+	here        int            // data space pointer, the end of mem
+	goWords     []userWord     // words added by Define
+	cellBits    int            // width of a cell
+	minCell     int            // smallest number a cell holds
+	maxCell     int            // largest number a cell holds
+	overflow    Overflow       // what arithmetic does when a result doesn't fit
+	texts       []string       // texts printed by compiled code
+	textIndex   map[string]int // text -> index in texts
+	floatStack  []float64      // floating point numbers
+	floats      []float64      // float literals of compiled code
+	floatIndex  map[uint64]int // bits of a float literal -> index in floats
+	stringIndex map[string]int // text of a string literal -> its address
+	fsys        fs.FS          // where INCLUDE and REQUIRE find files
+	included    map[string]int // files included so far -> their order
+	including   []string       // files being included, innermost last
+	hold        int            // start of the pictured output in the hold area
+	transient   int            // the transient string buffer used last, 0 or 1
+}
 
-	// val will contain one or more Forth statements.
-	// Each of them needs to be parsed and evaluated separately
-	for _, st := range val {
-		items := itemize(st)
-		if err := parse(items, valueStack, userWords); err != nil {
-			return nil, err
-		}
+// New returns a Machine with an empty stack and no user defined words
+func New(opts ...Option) *Machine {
+	m := &Machine{
+		limits: DefaultLimits,
+		out:    io.Discard,
+		in:     bufio.NewReader(strings.NewReader("")),
+	}
+	m.setCellBits(bits.UintSize)
+	for _, opt := range opts {
+		opt(m)
 	}
-	return valueStack.item, nil
+	m.Reset()
+	return m
 }
 
-// parse does the heavylifting of the statement evaluation.
-// Evaluation result stays in the modified valueStack.
-func parse(items []string, valueStack *stack, userWords map[string][]string) error {
+// Eval compiles and evaluates a single Forth statement.
+// The stack and the dictionary keep their state after the call.
+// A failure is reported as *Error. As in Forth, which interprets a token
+// at a time, the tokens before the failing one have been run then.
+func (m *Machine) Eval(line string) error {
+	return m.EvalContext(context.Background(), line)
+}
 
-	index := 0
-	for index < len(items) {
-		i, err := strconv.Atoi(items[index])
+// EvalContext is like Eval, but stops the evaluation
+// once ctx is done, failing with the error of ctx
+func (m *Machine) EvalContext(ctx context.Context, line string) error {
+	return m.eval(ctx, newScanner(strings.NewReader(line)), sourceEnd)
+}
 
-		// parsed without problems -> integer value
-		if err == nil {
-			valueStack.push(i)
-		} else {
-			// if ":" is the first word -> definition follows
-			if index == 0 && items[index] == ":" {
-				err := addWordToDict(items, userWords)
-				if err != nil {
-					return err
-				}
-				// return nil (no error, word inserted OK)
-				return nil
-			}
+// EvalReader evaluates the Forth source read from r, a statement at
+// a time. A statement ends with its line, unless a definition continues on
+// the next one, so scripts of any size can be evaluated without reading
+// them whole. It stops at the first failure, which is reported as *Error.
+func (m *Machine) EvalReader(r io.Reader) error {
+	return m.EvalReaderContext(context.Background(), r)
+}
+
+// EvalReaderContext is like EvalReader, but stops the evaluation
+// once ctx is done, failing with the error of ctx
+func (m *Machine) EvalReaderContext(ctx context.Context, r io.Reader) error {
+	rr, ok := r.(io.RuneReader)
+	if !ok {
+		rr = bufio.NewReader(r)
+	}
+	return m.evalSource(ctx, newScanner(rr), false)
+}
+
+// Interpret evaluates the Forth source read from r a statement at a time,
+// for interactive use. A statement ends with its line, even an empty one,
+// unless a definition continues on the next line. After each statement
+// done is called with its failure, if any, before more input is read, and
+// the rest of the line of a failed statement is skipped. Interpret returns
+// at the end of r, or once done returns false, with the error reading r.
+func (m *Machine) Interpret(r io.Reader, done func(error) bool) error {
+	return m.InterpretContext(context.Background(), r, done)
+}
+
+// InterpretContext is like Interpret, but stops the evaluation
+// once ctx is done, returning the error of ctx
+func (m *Machine) InterpretContext(ctx context.Context, r io.Reader, done func(error) bool) error {
+	rr, ok := r.(io.RuneReader)
+	if !ok {
+		rr = bufio.NewReader(r)
+	}
+	sc := newScanner(rr)
+	for sc.more() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		err := m.eval(ctx, sc, everyLineEnd)
+		if err != nil && !sc.newline {
+			sc.skipLine()
+		}
+		if !done(err) {
+			return nil
+		}
+	}
+	if sc.err != io.EOF {
+		return sc.err
+	}
+	return nil
+}
 
-			// in any other case try to parse existing words:
-			// start with user defined words as redefinition is allowed
-			if _, ok := userWords[items[index]]; ok {
-
-				// parse the value from the user word dictionary.
-				// stack, index and userWords should stay unchanged
-				parse(userWords[items[index]], valueStack, userWords)
-			} else if isWord(items[index]) {
-				if err := eval(items[index], valueStack); err != nil {
-					return err
-				}
-			} else {
-				return errors.New("Wrong syntax?")
+// evalSource evaluates the statements read by sc up to its end. Unless
+// nested in another statement, they are counted by the machine; nested
+// ones are counted from the start of sc, as they belong to a file.
+func (m *Machine) evalSource(ctx context.Context, sc *scanner, nested bool) error {
+	n := 0 // statements of sc evaluated so far
+	statement := func() int {
+		if nested {
+			return n
+		}
+		return m.statement
+	}
+	for ; !sc.atEnd(); n++ {
+		if err := ctx.Err(); err != nil {
+			return &Error{Statement: statement(), Stack: m.Stack(), Err: err}
+		}
+		var err error
+		if nested {
+			err = m.evalStatement(ctx, sc, lineEnd)
+			if e, ok := err.(*Error); ok && e.File == "" {
+				e.Statement = n
 			}
+		} else {
+			err = m.eval(ctx, sc, lineEnd)
 		}
-		index++
+		if err != nil {
+			return err
+		}
+	}
+	if sc.err != io.EOF {
+		return &Error{Statement: statement(), Stack: m.Stack(), Err: sc.err}
 	}
 	return nil
 }
 
-// add a user defined word to dictionary:
-func addWordToDict(items []string, userWords map[string][]string) error {
+// eval evaluates the next statement read by sc, counting it
+func (m *Machine) eval(ctx context.Context, sc *scanner, end ending) error {
+	m.steps, m.ctx = 0, ctx
+	err := m.evalStatement(ctx, sc, end)
+	if e, ok := err.(*Error); ok && e.File == "" {
+		e.Statement = m.statement
+	}
+	m.statement++
+	return err
+}
 
-	// 0. can't be empty: has to contain :, ;, word and def -> min 4 items
-	if len(items) < 4 {
-		return errors.New("Invalid word definition - too short")
+// Stack returns a copy of the current stack, bottom element first
+func (m *Machine) Stack() []int {
+	return append([]int{}, m.valueStack.item...)
+}
+
+// StackEffect returns the stack effect comment following the name of the
+// user defined word name, like "n -- n*n" for : sq ( n -- n*n ) dup * ;
+// It reports false if name isn't a user defined word, and returns ""
+// if the word has no comment.
+func (m *Machine) StackEffect(name string) (string, bool) {
+	w, ok := m.dict[strings.ToUpper(name)]
+	if !ok {
+		return "", false
 	}
+	return m.words[w].effect, true
+}
 
-	// 1. it has to end with the semicolon:
-	if items[len(items)-1] != ";" {
-		return errors.New("User word definition doesn't end with ;")
+// Words returns the names of all words the machine knows: the user defined
+// ones first, newest first, then the builtins which haven't been redefined
+func (m *Machine) Words() []string {
+	var names []string
+	for _, d := range m.Dictionary() {
+		names = append(names, d.Name)
 	}
+	return names
+}
 
-	// 2. can't redefine numbers
-	if _, err := strconv.Atoi(items[1]); err == nil {
-		return errors.New("Can't redefine numbers")
+// Reset empties the stack and forgets all user defined words.
+// The options given to New and the words added by Define stay in effect.
+func (m *Machine) Reset() {
+	m.valueStack = newStack()
+	m.returnStack = newStack()
+	m.words = nil
+	m.dict = make(map[string]int)
+	m.texts = nil
+	m.textIndex = make(map[string]int)
+	m.floatStack = nil
+	m.floats = nil
+	m.floatIndex = make(map[uint64]int)
+	m.stringIndex = make(map[string]int)
+	m.included = make(map[string]int)
+	m.hold = holdAddr + holdSize
+	m.here = userAddr
+	m.mem = make([]int, m.here)
+	m.mem[baseAddr] = 10
+	m.statement = 0
+	for _, w := range m.goWords {
+		w.mark = m.mark()
+		m.addWord(w)
 	}
-	userWords[items[1]] = items[2 : len(items)-1]
-	return nil
 }
 
-// evaluate word expression:
-func eval(word string, s *stack) error {
-	switch strings.ToUpper(word) {
-	case "+":
-		return binaryOp(s, func(a, b int) (int, error) { return a + b, nil })
-	case "-":
-		return binaryOp(s, func(a, b int) (int, error) { return a - b, nil })
-	case "*":
-		return binaryOp(s, func(a, b int) (int, error) { return a * b, nil })
-	case "/":
-		return binaryOp(s, func(a, b int) (int, error) {
-			if b == 0 {
-				return 0, errors.New("Dividing by 0")
-			}
-			return a / b, nil
-		})
-	case "DUP":
-		return dup(s)
-	case "DROP":
-		return drop(s)
-	case "SWAP":
-		return swap(s)
-	case "OVER":
-		return over(s)
+// Forth is the main evaluator function
+func Forth(val []string) ([]int, error) {
+	m := New()
+
+	// val will contain one or more Forth statements.
+	// Each of them needs to be parsed and evaluated separately
+	for _, st := range val {
+		if err := m.Eval(st); err != nil {
+			return nil, err
+		}
 	}
-	return nil
+	return m.Stack(), nil
 }
 
 // dup duplicates the top element of the stack:
@@ -219,22 +344,20 @@ func binaryOp(s *stack, op func(int, int) (int, error)) error {
 	return nil
 }
 
-// divide forth statement into list of items:
-// use regular expression to clean multiple white characters
-func itemize(st string) []string {
-	re1 := regexp.MustCompile("[\t\n\v\f\r\x00\x13]")
-	re2 := regexp.MustCompile(" {2,}")
-	st = re1.ReplaceAllString(st, " ")
-	st = re2.ReplaceAllString(st, " ")
-	return strings.Split(st, " ")
+// unaryOp replaces the top element of the stack with op applied to it
+func unaryOp(s *stack, op func(int) int) error {
+	op1, err := s.pop()
+	if err != nil {
+		return err
+	}
+	s.push(op(op1))
+	return nil
 }
 
-// isWord checks if an item is a defined Forth word
-func isWord(word string) bool {
-	for _, i := range forthWords {
-		if i == strings.ToUpper(word) {
-			return true
-		}
+// flag converts a Go bool into a Forth flag
+func flag(b bool) int {
+	if b {
+		return flagTrue
 	}
-	return false
+	return flagFalse
 }
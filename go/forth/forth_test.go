@@ -5,8 +5,18 @@ package forth
 //
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
 	"reflect"
+	"strings"
 	"testing"
+	"testing/fstest"
+	"testing/iotest"
+	"time"
 )
 
 const targetTestVersion = 1
@@ -62,3 +72,1135 @@ func BenchmarkForth(b *testing.B) {
 		}
 	}
 }
+
+// deepNesting defines w0 .. w9, each word calling the previous one twice,
+// so executing w9 ends up in 512 additions
+var deepNesting = func() []string {
+	defs := []string{": w0 1 + ;"}
+	for i := 1; i < 10; i++ {
+		defs = append(defs, fmt.Sprintf(": w%d w%d w%d ;", i, i-1, i-1))
+	}
+	return defs
+}()
+
+func BenchmarkDeepNesting(b *testing.B) {
+	m := New()
+	for _, def := range deepNesting {
+		if err := m.Eval(def); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := m.Eval("0 w9 drop"); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestMachineKeepsState(t *testing.T) {
+	m := New()
+	for _, line := range []string{": square dup * ;", "3", "square 2"} {
+		if err := m.Eval(line); err != nil {
+			t.Fatalf("Eval(%q) returned error: %v", line, err)
+		}
+	}
+	if v := m.Stack(); !reflect.DeepEqual(v, []int{9, 2}) {
+		t.Fatalf("Stack() = %v, want [9 2]", v)
+	}
+	if err := m.Eval("square"); err != nil {
+		t.Fatalf("user word lost between calls: %v", err)
+	}
+	if v := m.Stack(); !reflect.DeepEqual(v, []int{9, 4}) {
+		t.Fatalf("Stack() = %v, want [9 4]", v)
+	}
+}
+
+func TestMachineStackIsCopy(t *testing.T) {
+	m := New()
+	m.Eval("1 2")
+	m.Stack()[0] = 42
+	if v := m.Stack(); !reflect.DeepEqual(v, []int{1, 2}) {
+		t.Fatalf("Stack() = %v after modifying a previous result, want [1 2]", v)
+	}
+}
+
+func TestMachineReset(t *testing.T) {
+	m := New()
+	m.Eval(": foo 1 ;")
+	m.Eval("foo 2")
+	m.Reset()
+	if v := m.Stack(); len(v) != 0 {
+		t.Fatalf("Stack() = %v after Reset, want empty stack", v)
+	}
+	if err := m.Eval("foo"); err == nil {
+		t.Fatal("user defined word survived Reset")
+	}
+}
+
+func TestErrorValues(t *testing.T) {
+	tests := []struct {
+		input     []string
+		want      error
+		statement int
+		token     int
+		word      string
+		stack     []int
+	}{
+		{[]string{"1 2", "3 +", "+ +"}, ErrStackUnderflow, 2, 1, "+", []int{}},
+		{[]string{"4 0 /"}, ErrDivisionByZero, 0, 2, "/", []int{}},
+		{[]string{"1", "2 foo"}, ErrUnknownWord, 1, 1, "foo", []int{1, 2}},
+		{[]string{"1 dup foo"}, ErrUnknownWord, 0, 2, "foo", []int{1, 1}},
+		{[]string{"hex 10 decimal 10 foo"}, ErrUnknownWord, 0, 4, "foo", []int{16, 10}},
+		{[]string{": 1 2 ;"}, ErrInvalidDefinition, 0, 1, "1", []int{}},
+		{[]string{"5", ": foo 1 2"}, ErrUnterminatedDefinition, 1, 1, "foo", []int{5}},
+	}
+	for _, tc := range tests {
+		m := New()
+		var err error
+		for _, line := range tc.input {
+			if err = m.Eval(line); err != nil {
+				break
+			}
+		}
+		if !errors.Is(err, tc.want) {
+			t.Fatalf("%#v: got error %v, want %v", tc.input, err, tc.want)
+		}
+		var e *Error
+		if !errors.As(err, &e) {
+			t.Fatalf("%#v: error %v is not *Error", tc.input, err)
+		}
+		if e.Statement != tc.statement || e.Token != tc.token || e.Word != tc.word {
+			t.Fatalf("%#v: error at statement %d, token %d, word %q, want %d, %d, %q",
+				tc.input, e.Statement, e.Token, e.Word, tc.statement, tc.token, tc.word)
+		}
+		if !reflect.DeepEqual(e.Stack, tc.stack) {
+			t.Fatalf("%#v: error stack %v, want %v", tc.input, e.Stack, tc.stack)
+		}
+	}
+}
+
+func TestErrorTrace(t *testing.T) {
+	m := New()
+	for _, line := range []string{": bar 0 / ;", ": foo 1 bar ;"} {
+		if err := m.Eval(line); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err := m.Eval("2 3 foo")
+	var e *Error
+	if !errors.As(err, &e) || !errors.Is(err, ErrDivisionByZero) {
+		t.Fatalf("got error %v, want division by zero", err)
+	}
+	if !reflect.DeepEqual(e.Trace, []string{"foo", "bar"}) || e.Word != "/" || e.Token != 2 {
+		t.Fatalf("error trace %v, word %q, token %d, want [foo bar], \"/\", 2",
+			e.Trace, e.Word, e.Token)
+	}
+	if want := "statement 2, token 2: foo -> bar -> /: division by zero"; err.Error() != want {
+		t.Fatalf("got message %q, want %q", err.Error(), want)
+	}
+
+	m = New(WithLimits(Limits{Steps: 100}))
+	m.Eval(": baz begin 0 until ;")
+	m.Eval(": foo baz ;")
+	err = m.Eval("foo")
+	if want := "statement 2, token 0: foo -> baz: step limit exceeded"; err == nil || err.Error() != want {
+		t.Fatalf("got message %q, want %q", err, want)
+	}
+}
+
+func TestReturnStackOverflow(t *testing.T) {
+	m := New()
+	m.Eval(": w0 1 ;")
+	for i := 1; i <= DefaultLimits.ReturnStack; i++ {
+		if err := m.Eval(fmt.Sprintf(": w%d w%d ;", i, i-1)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	err := m.Eval(fmt.Sprintf("w%d", DefaultLimits.ReturnStack))
+	if !errors.Is(err, ErrReturnStackOverflow) {
+		t.Fatalf("got error %v, want %v", err, ErrReturnStackOverflow)
+	}
+	if err := m.Eval("w10"); err != nil {
+		t.Fatalf("machine unusable after return stack overflow: %v", err)
+	}
+	if v := m.Stack(); !reflect.DeepEqual(v, []int{1}) {
+		t.Fatalf("Stack() = %v, want [1]", v)
+	}
+}
+
+func TestLimits(t *testing.T) {
+	tests := []struct {
+		description string
+		limits      Limits
+		input       []string
+		want        error
+	}{
+		{
+			"endless loop hits the step limit",
+			Limits{Steps: 1000},
+			[]string{": foo begin 0 until ;", "foo"},
+			ErrStepLimit,
+		},
+		{
+			"step limit is per statement",
+			Limits{Steps: 20},
+			[]string{"1 2 3 4 5 6 7 8 9 10", "1 2 3 4 5 6 7 8 9 10"},
+			nil,
+		},
+		{
+			"the last instruction may use up the step limit",
+			Limits{Steps: 2},
+			[]string{"1 2"},
+			nil,
+		},
+		{
+			"the last instruction may hit the stack limit",
+			Limits{Stack: 3},
+			[]string{"1 2 3 4"},
+			ErrStackOverflow,
+		},
+		{
+			"a word may fill the stack up to the limit",
+			DefaultLimits,
+			[]string{": f 0 do 1 loop ;", "65536 f 1"},
+			ErrStackOverflow,
+		},
+		{
+			"definitions don't restart the step count",
+			Limits{Steps: 100},
+			[]string{": w 40 0 do loop ;", "w : a ; w : b ; w : c ; w"},
+			ErrStepLimit,
+		},
+		{
+			"numbers after words don't restart the step count",
+			Limits{Steps: 100},
+			[]string{": w 40 0 do loop ;", "w 1 drop w 1 drop w 1 drop w 1 drop w"},
+			ErrStepLimit,
+		},
+		{
+			"printing many spaces hits the step limit",
+			Limits{Steps: 100},
+			[]string{"-1 1 rshift spaces"},
+			ErrStepLimit,
+		},
+		{
+			"growing the stack hits the stack limit",
+			Limits{Stack: 100},
+			[]string{": foo begin 1 0 until ;", "foo"},
+			ErrStackOverflow,
+		},
+		{
+			"nested loops hit the return stack limit",
+			Limits{ReturnStack: 5},
+			[]string{": foo 3 0 do 3 0 do 3 0 do i loop loop loop ;", "foo"},
+			ErrReturnStackOverflow,
+		},
+		{
+			"definitions hit the dictionary limit",
+			Limits{Words: 2},
+			[]string{": foo 1 ;", ": foo 2 ;", ": bar 3 ;"},
+			ErrDictionaryFull,
+		},
+		{
+			"zero limits are no limits",
+			Limits{},
+			[]string{": foo 1000 0 do i loop ;", "foo foo foo"},
+			nil,
+		},
+	}
+	for _, tc := range tests {
+		m := New(WithLimits(tc.limits))
+		var err error
+		for _, line := range tc.input {
+			if err = m.Eval(line); err != nil {
+				break
+			}
+		}
+		if !errors.Is(err, tc.want) {
+			t.Fatalf("%s: got error %v, want %v", tc.description, err, tc.want)
+		}
+	}
+}
+
+func TestEvalContextCancel(t *testing.T) {
+	m := New()
+	if err := m.Eval(": forever begin 0 until ;"); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	done := make(chan error)
+	go func() { done <- m.EvalContext(ctx, "1 forever") }()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("got error %v, want %v", err, context.DeadlineExceeded)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("evaluation didn't stop after the context was done")
+	}
+
+	// builtins doing a lot of work stop as well
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	go func() { done <- m.EvalContext(ctx, "-1 1 rshift spaces") }()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("got error %v, want %v", err, context.DeadlineExceeded)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("SPACES didn't stop after the context was done")
+	}
+
+	if err := m.Eval("2"); err != nil {
+		t.Fatalf("machine unusable after cancellation: %v", err)
+	}
+	if v := m.Stack(); v[0] != 1 || v[len(v)-1] != 2 {
+		t.Fatalf("Stack() = %v, want 1 at the bottom and 2 on top", v)
+	}
+}
+
+func TestWords(t *testing.T) {
+	m := New()
+	m.Eval(": foo 1 ; : bar 2 ; : FOO 3 ; : dup 4 ;")
+	words := m.Words()
+	if want := []string{"dup", "FOO", "bar"}; !reflect.DeepEqual(words[:3], want) {
+		t.Fatalf("Words() starts with %v, want %v", words[:3], want)
+	}
+	seen := map[string]bool{}
+	for _, w := range words {
+		if seen[strings.ToUpper(w)] {
+			t.Fatalf("Words() lists %q twice", w)
+		}
+		seen[strings.ToUpper(w)] = true
+	}
+	for _, w := range []string{"+", "SWAP", "IF", "LOOP", ">R", ":"} {
+		if !seen[w] {
+			t.Fatalf("Words() = %v, missing builtin %q", words, w)
+		}
+	}
+}
+
+func TestOutput(t *testing.T) {
+	tests := []struct {
+		description string
+		input       []string
+		output      string
+		stack       []int
+	}{
+		{"dot prints the top of the stack", []string{"1 2 . ."}, "2 1 ", []int{}},
+		{"negative numbers", []string{"-5 ."}, "-5 ", []int{}},
+		{"emit prints a character", []string{"72 emit 105 EMIT"}, "Hi", []int{}},
+		{"emit prints non-ASCII characters", []string{"955 emit"}, "λ", []int{}},
+		{"cr prints a new line", []string{"1 . cr 2 ."}, "1 \n2 ", []int{}},
+		{"space and spaces", []string{"space 3 spaces 0 spaces -1 spaces"}, "    ", []int{}},
+		{"many spaces", []string{"100 spaces"}, strings.Repeat(" ", 100), []int{}},
+		{".s prints the stack unchanged", []string{"1 2 .s"}, "<2> 1 2 ", []int{1, 2}},
+		{".s of an empty stack", []string{".s"}, "<0> ", []int{}},
+		{".\" prints text", []string{`." hello"`}, "hello", []int{}},
+		{".\" keeps spaces and case", []string{`."  Hello,  World! " 1`}, " Hello,  World! ", []int{1}},
+		{".\" in a definition", []string{`: hi ." hi there" cr ;`, "hi hi"}, "hi there\nhi there\n", []int{}},
+		{".\" text may look like words", []string{`: foo ." : ; 1 dup" ;`, "foo"}, ": ; 1 dup", []int{}},
+		{".\" without closing quote prints the rest", []string{`." open`}, "open", []int{}},
+		{"d. prints a double", []string{"-1. d. 0 1 d."}, "-1 18446744073709551616 ", []int{}},
+		{"f. prints a float", []string{"1.5e3 f. 0.25 f. -3e f."}, "1500 0.25 -3 ", []int{}},
+		{"dot prints in the current base", []string{"255 hex . -10 . 5 binary . decimal 10 ."}, "FF -10 101 10 ", []int{}},
+		{".s prints in the current base", []string{"10 -1 hex .s"}, "<2> A -1 ", []int{10, -1}},
+		{"d. prints in the current base", []string{"0 1 hex d."}, "10000000000000000 ", []int{}},
+		{"type prints a string", []string{`s" Hello, World!" type`}, "Hello, World!", []int{}},
+		{"type a counted string", []string{`: hi c" hi there" count type ;`, "hi"}, "hi there", []int{}},
+		{"pictured output", []string{"-1234 dup abs 0 <# #s rot sign #> type"}, "-1234", []int{}},
+		{"pictured output in hex", []string{"255. hex <# # # '$' hold #> type"}, "$FF", []int{}},
+		{"pictured output of a double", []string{"0 1 <# #s #> type"}, "18446744073709551616", []int{}},
+		{"output inside loops", []string{": stars 0 do 42 emit loop ;", "5 stars"}, "*****", []int{}},
+	}
+	for _, tc := range tests {
+		var out bytes.Buffer
+		m := New(WithOutput(&out))
+		for _, line := range tc.input {
+			if err := m.Eval(line); err != nil {
+				t.Fatalf("%s: Eval(%q) returned error: %v", tc.description, line, err)
+			}
+		}
+		if out.String() != tc.output {
+			t.Fatalf("%s: got output %q, want %q", tc.description, out.String(), tc.output)
+		}
+		if v := m.Stack(); !reflect.DeepEqual(v, tc.stack) {
+			t.Fatalf("%s: Stack() = %v, want %v", tc.description, v, tc.stack)
+		}
+	}
+}
+
+func TestOutputErrors(t *testing.T) {
+	for _, line := range []string{".", "emit", "spaces"} {
+		if err := New().Eval(line); !errors.Is(err, ErrStackUnderflow) {
+			t.Fatalf("Eval(%q) returned %v, want %v", line, err, ErrStackUnderflow)
+		}
+	}
+}
+
+func TestOutputDiscardedByDefault(t *testing.T) {
+	if v, err := Forth([]string{`1 2 . ." text" cr .s`}); err != nil || !reflect.DeepEqual(v, []int{1}) {
+		t.Fatalf("Forth returned %v, %v, want [1]", v, err)
+	}
+}
+
+func TestInput(t *testing.T) {
+	tests := []struct {
+		description string
+		input       string
+		program     []string
+		stack       []int
+		output      string
+	}{
+		{"key reads characters", "ab", []string{"key key"}, []int{'a', 'b'}, ""},
+		{"key reads non-ASCII characters", "λx", []string{"key"}, []int{'λ'}, ""},
+		{"key? is true while there is input", "a", []string{"key? key key?"}, []int{-1, 'a', 0}, ""},
+		{"key? on empty input", "", []string{"key?"}, []int{0}, ""},
+		{
+			"copies input to output",
+			"Hello\nWorld",
+			[]string{": copy begin key? while key emit repeat ;", "copy"},
+			[]int{},
+			"Hello\nWorld",
+		},
+		{
+			"accept reads a line",
+			"hello\nworld\n",
+			[]string{"pad 80 accept pad 80 accept key?"},
+			[]int{5, 5, 0},
+			"",
+		},
+		{"accept stops at the buffer size", "hello\nworld", []string{"pad 3 accept key"}, []int{3, 'w'}, ""},
+		{"accept drops crlf", "hi\r\n", []string{"pad 80 accept"}, []int{2}, ""},
+		{"accept at the end of input", "", []string{"pad 80 accept"}, []int{0}, ""},
+	}
+	for _, tc := range tests {
+		var out bytes.Buffer
+		m := New(WithInput(strings.NewReader(tc.input)), WithOutput(&out))
+		for _, line := range tc.program {
+			if err := m.Eval(line); err != nil {
+				t.Fatalf("%s: Eval(%q) returned error: %v", tc.description, line, err)
+			}
+		}
+		if v := m.Stack(); !reflect.DeepEqual(v, tc.stack) {
+			t.Fatalf("%s: Stack() = %v, want %v", tc.description, v, tc.stack)
+		}
+		if out.String() != tc.output {
+			t.Fatalf("%s: got output %q, want %q", tc.description, out.String(), tc.output)
+		}
+	}
+}
+
+func TestAcceptStoresLine(t *testing.T) {
+	m := New(WithInput(strings.NewReader("héllo\n")))
+	if err := m.Eval("pad 80 accept"); err != nil {
+		t.Fatal(err)
+	}
+	var got []rune
+	for _, c := range m.mem[padAddr : padAddr+m.Stack()[0]] {
+		got = append(got, rune(c))
+	}
+	if string(got) != "héllo" {
+		t.Fatalf("accept stored %q, want %q", string(got), "héllo")
+	}
+}
+
+func TestInputErrors(t *testing.T) {
+	tests := []struct {
+		line string
+		want error
+	}{
+		{"key", io.EOF},
+		{"0 10 accept", ErrInvalidAddress},
+		{"pad 1000 accept", ErrInvalidAddress},
+		{"pad -1 accept", ErrInvalidAddress},
+		{"1 accept", ErrStackUnderflow},
+	}
+	for _, tc := range tests {
+		if err := New().Eval(tc.line); !errors.Is(err, tc.want) {
+			t.Fatalf("Eval(%q) returned %v, want %v", tc.line, err, tc.want)
+		}
+	}
+}
+
+func TestMemoryLimit(t *testing.T) {
+	l := DefaultLimits
+	l.Memory = userAddr + 800
+	m := New(WithLimits(l))
+	if err := m.Eval("500 allot"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Eval("500 allot"); !errors.Is(err, ErrMemoryFull) {
+		t.Fatalf("got error %v, want %v", err, ErrMemoryFull)
+	}
+	if err := m.Eval("-500 allot 500 allot"); err != nil {
+		t.Fatalf("released memory can't be allotted again: %v", err)
+	}
+
+	m = New(WithLimits(Limits{}))
+	if err := m.Eval("-1 1 rshift 100000 - allot"); !errors.Is(err, ErrMemoryFull) {
+		t.Fatalf("got error %v without a memory limit, want %v", err, ErrMemoryFull)
+	}
+}
+
+func TestDefine(t *testing.T) {
+	m := New()
+	if err := m.Eval(": old 2 * ;  : twice old ;"); err != nil {
+		t.Fatal(err)
+	}
+	sum := func(m *Machine) error {
+		v, err := m.PopN(3)
+		if err != nil {
+			return err
+		}
+		m.Push(v[0] + v[1] + v[2])
+		return nil
+	}
+	if err := m.Define("Sum3", sum); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Define("old", func(m *Machine) error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Define("dup", func(m *Machine) error {
+		v, err := m.Peek()
+		if err == nil {
+			m.Push(v * 10)
+		}
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Eval("1 2 3 sum3 5 twice 7 OLD 4 dup : s sum3 ; 1 1 1 s"); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := m.Stack(), []int{6, 10, 7, 4, 40, 3}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	m.Reset()
+	if err := m.Eval("1 2 3 SUM3"); err != nil {
+		t.Fatalf("defined word lost by Reset: %v", err)
+	}
+	err := m.Eval("1 sum3")
+	var e *Error
+	if !errors.As(err, &e) || !errors.Is(err, ErrStackUnderflow) || e.Word != "Sum3" {
+		t.Fatalf("got error %#v, want stack underflow in Sum3", err)
+	}
+	if got, want := m.Stack(), []int{6, 1}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("PopN changed the stack on error: got %v, want %v", got, want)
+	}
+	for _, name := range []string{"", "12", "two words", "no\u00a0break", "nul\x00", "dc3\x13"} {
+		if err := m.Define(name, sum); !errors.Is(err, ErrInvalidDefinition) {
+			t.Errorf("Define(%q) = %v, want %v", name, err, ErrInvalidDefinition)
+		}
+	}
+}
+
+func TestDefineErrors(t *testing.T) {
+	errHost := errors.New("host failure")
+	m := New()
+	m.Define("fail", func(m *Machine) error { return errHost })
+	if err := m.Eval(": f 1 fail ; 2 f"); !errors.Is(err, errHost) {
+		t.Fatalf("got error %v, want %v", err, errHost)
+	}
+	if _, err := m.Pop(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Pop(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Pop(); !errors.Is(err, ErrStackUnderflow) {
+		t.Fatalf("got error %v, want %v", err, ErrStackUnderflow)
+	}
+	if _, err := m.Peek(); !errors.Is(err, ErrStackUnderflow) {
+		t.Fatalf("got error %v, want %v", err, ErrStackUnderflow)
+	}
+}
+
+func TestCellBits(t *testing.T) {
+	for _, tc := range []struct {
+		bits     int
+		overflow Overflow
+		input    string
+		want     []int
+		err      error
+	}{
+		{16, OverflowWrap, "32767 1 +", []int{-32768}, nil},
+		{16, OverflowWrap, "-32768 1 -", []int{32767}, nil},
+		{16, OverflowWrap, "256 256 *", []int{0}, nil},
+		{16, OverflowWrap, "-32768 -1 /", []int{-32768}, nil},
+		{16, OverflowWrap, "-32768 negate -32768 abs", []int{-32768, -32768}, nil},
+		{16, OverflowWrap, "65535", []int{-1}, nil},
+		{16, OverflowWrap, "-1 1 rshift 16384 2* 1 16 lshift", []int{32767, -32768, 0}, nil},
+		{16, OverflowSaturate, "32767 1 + -32768 1 - 300 -300 *", []int{32767, -32768, -32768}, nil},
+		{16, OverflowSaturate, "-32768 -1 / 100000", []int{32767, 32767}, nil},
+		{16, OverflowError, "32767 1+", []int{32767}, ErrOverflow},
+		{16, OverflowError, "-32768 -1 /mod", []int{-32768, -1}, ErrOverflow},
+		{16, OverflowError, "40000 70000", []int{-25536}, ErrOverflow},
+		{16, OverflowSaturate, "65535 65536", []int{-1, 32767}, nil},
+		{32, OverflowError, "$FFFFFFFF $80000000 4294967295", []int{-1, -2147483648, -1}, nil},
+		{32, OverflowError, "$100000000", []int{}, ErrOverflow},
+		{16, OverflowError, "16384 2*", []int{-32768}, nil},
+		{16, OverflowError, "30000 3 4 */ 32767. 1. d+", []int{22500, -32768, 0}, nil},
+		{16, OverflowError, "-1 0 1 um/mod", []int{0, -1}, nil},
+		{16, OverflowError, "0 1 1 um/mod", []int{0, 1, 1}, ErrOverflow},
+		{16, OverflowSaturate, "0 1 1 um/mod -32768 -1 1 */", []int{0, -1, 32767}, nil},
+		{16, OverflowWrap, "65536. -2147483648. dnegate", []int{0, 1, 0, -32768}, nil},
+		{16, OverflowError, "2147483648.", []int{}, ErrOverflow},
+		{16, OverflowSaturate, "1e9 f>s -1e9 f>s", []int{32767, -32768}, nil},
+		{16, OverflowWrap, "65537e f>s", []int{1}, nil},
+		{16, OverflowError, "1e9 f>s", []int{}, ErrOverflow},
+		{32, OverflowWrap, "2147483647 1 +", []int{-2147483648}, nil},
+		{32, OverflowError, "65536 dup *", []int{}, ErrOverflow},
+		{64, OverflowWrap, "9223372036854775807 1 +", []int{minInt}, nil},
+		{64, OverflowSaturate, "9223372036854775807 2 * -9223372036854775807 2 -", []int{-minInt - 1, minInt}, nil},
+		{64, OverflowSaturate, "-9223372036854775807 1- -1 *", []int{-minInt - 1}, nil},
+		{64, OverflowError, "variable v 9223372036854775807 v ! 1 v +!", []int{}, ErrOverflow},
+	} {
+		m := New(WithCellBits(tc.bits), WithOverflow(tc.overflow))
+		err := m.Eval(tc.input)
+		if !errors.Is(err, tc.err) {
+			t.Errorf("%d bits %q: got error %v, want %v", tc.bits, tc.input, err, tc.err)
+		}
+		if got := m.Stack(); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%d bits %q: got %v, want %v", tc.bits, tc.input, got, tc.want)
+		}
+	}
+}
+
+func TestCellBitsCharacters(t *testing.T) {
+	m := New(WithCellBits(16), WithInput(strings.NewReader("😀😀\n")))
+	if err := m.Eval(`key pad 10 accept pad @ s" 😀" drop @`); err != nil {
+		t.Fatal(err)
+	}
+	// U+1F600 doesn't fit in a cell, its low 16 bits are kept
+	if got, want := m.Stack(), []int{-2560, 1, -2560, -2560}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
+
+func TestCellBitsInvalid(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("New accepted 8 bit cells")
+		}
+	}()
+	New(WithCellBits(8))
+}
+
+func TestFloatStack(t *testing.T) {
+	m := New()
+	if err := m.Eval("1 2.5 3e -0.5e1 4"); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := m.FloatStack(), []float64{2.5, 3, -5}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got float stack %v, want %v", got, want)
+	}
+	if got, want := m.Stack(), []int{1, 4}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got stack %v, want %v", got, want)
+	}
+	m.FloatStack()[0] = 0
+	if m.FloatStack()[0] != 2.5 {
+		t.Fatal("FloatStack returned the internal stack")
+	}
+	if err := m.Eval("fdrop fdrop fdrop fdrop"); !errors.Is(err, ErrFloatStackUnderflow) {
+		t.Fatalf("got error %v, want %v", err, ErrFloatStackUnderflow)
+	}
+	m.Eval("1e")
+	m.Reset()
+	if got := m.FloatStack(); len(got) != 0 {
+		t.Fatalf("Reset kept the float stack %v", got)
+	}
+}
+
+func TestInterpretedFloatsAreNotKept(t *testing.T) {
+	m := New()
+	if err := m.Eval(": half 0.5e ;"); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 100; i++ {
+		if err := m.Eval(fmt.Sprintf("%d.25e half f+ fdrop", i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := m.Eval("1.5e 2.5e half f+ f+"); err != nil {
+		t.Fatal(err)
+	}
+	if got := m.FloatStack(); !reflect.DeepEqual(got, []float64{4.5}) {
+		t.Fatalf("got float stack %v, want [4.5]", got)
+	}
+	if len(m.floats) != 1 {
+		t.Fatalf("interpreted float literals are kept: %v", m.floats)
+	}
+}
+
+func TestStringLiteralsShareMemory(t *testing.T) {
+	m := New()
+	if err := m.Eval(`: a s" abc" drop here ; : b s" abc" drop here ; a b`); err != nil {
+		t.Fatal(err)
+	}
+	if s := m.Stack(); s[0] != s[2] || s[1] != s[3] {
+		t.Fatalf("equal literals are stored twice: %v", s)
+	}
+}
+
+func TestTransientStrings(t *testing.T) {
+	var out bytes.Buffer
+	m := New(WithOutput(&out))
+	if err := m.Eval("here"); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 100; i++ {
+		if err := m.Eval(fmt.Sprintf(`s" string %d" 2drop c" %[1]d" drop ." %[1]d"`, i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := m.Eval("here ="); err != nil {
+		t.Fatal(err)
+	}
+	if s := m.Stack(); !reflect.DeepEqual(s, []int{-1}) {
+		t.Fatalf("interpreted strings took data space: %v", s)
+	}
+	if len(m.texts) != 0 {
+		t.Fatalf("interpreted texts are kept: %q", m.texts)
+	}
+
+	out.Reset()
+	if err := m.Eval(`s" one" s" two" type type`); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "twoone" {
+		t.Fatalf("the last two strings printed %q, want %q", out.String(), "twoone")
+	}
+
+	long := `s" ` + strings.Repeat("x", stringSize) + `"`
+	if err := m.Eval(long); !errors.Is(err, ErrStringOverflow) {
+		t.Fatalf("got error %v, want %v", err, ErrStringOverflow)
+	}
+}
+
+func TestHoldOverflow(t *testing.T) {
+	m := New()
+	err := m.Eval(": stars <# 200 0 do '*' hold loop ; stars")
+	if !errors.Is(err, ErrHoldOverflow) {
+		t.Fatalf("got error %v, want %v", err, ErrHoldOverflow)
+	}
+}
+
+func TestStackEffect(t *testing.T) {
+	m := New()
+	err := m.Eval(": sq ( n -- n*n ) dup * ;  : cube dup ( n ) sq * ;  : two (  -- 2 ) 2 ;")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		name, effect string
+		ok           bool
+	}{
+		{"SQ", "n -- n*n", true},
+		{"cube", "", true},
+		{"two", "-- 2", true},
+		{"dup", "", false},
+		{"none", "", false},
+	} {
+		effect, ok := m.StackEffect(tc.name)
+		if effect != tc.effect || ok != tc.ok {
+			t.Errorf("StackEffect(%q) = %q, %v, want %q, %v", tc.name, effect, ok, tc.effect, tc.ok)
+		}
+	}
+}
+
+func TestScannerSpans(t *testing.T) {
+	sc := newScanner(strings.NewReader("  dup λx\n\t.\" hi there\"   \\ skipped\n( c )"))
+	var got []token
+	for {
+		tok, ok := sc.word()
+		if !ok {
+			break
+		}
+		got = append(got, tok)
+		switch tok.text {
+		case `."`:
+			got = append(got, sc.text('"'))
+		case "(":
+			got = append(got, sc.text(')'))
+		case `\`:
+			sc.skipLine()
+		}
+	}
+	want := []token{
+		{"dup", position{1, 3}, position{1, 5}},
+		{"λx", position{1, 7}, position{1, 8}},
+		{`."`, position{2, 2}, position{2, 3}},
+		{"hi there", position{2, 5}, position{2, 12}},
+		{`\`, position{2, 17}, position{2, 17}},
+		{"(", position{3, 1}, position{3, 1}},
+		{"c ", position{3, 3}, position{3, 4}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got tokens\n%v\nwant\n%v", got, want)
+	}
+}
+
+func TestErrorPosition(t *testing.T) {
+	m := New()
+	err := m.Eval("1 2\n  3 ( λ ) foo")
+	var e *Error
+	if !errors.As(err, &e) {
+		t.Fatalf("got error %v, want *Error", err)
+	}
+	if e.Token != 5 || e.Line != 2 || e.Column != 11 {
+		t.Fatalf("error at token %d, line %d, column %d, want token 5, line 2, column 11", e.Token, e.Line, e.Column)
+	}
+}
+
+func TestEvalReader(t *testing.T) {
+	m := New()
+	script := `
+: sq ( n -- n*n )
+  dup * ;
+3 sq
+variable v
+  5 v !
+v @ sq \ 25
+`
+	if err := m.EvalReader(strings.NewReader(script)); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := m.Stack(), []int{9, 25}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+
+	err := m.EvalReader(strings.NewReader("1\n: f\n 2 foo ;\n3"))
+	var e *Error
+	if !errors.As(err, &e) || !errors.Is(err, ErrUnknownWord) {
+		t.Fatalf("got error %v, want unknown word", err)
+	}
+	// the five statements of the first script, and "1"
+	if e.Statement != 6 || e.Token != 3 || e.Line != 3 || e.Column != 4 {
+		t.Fatalf("got error %#v, want statement 6, token 3 at line 3, column 4", e)
+	}
+	if got, want := m.Stack(), []int{9, 25, 1}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("evaluation didn't stop at the error: got %v, want %v", got, want)
+	}
+}
+
+// repeatReader repeats a text n times without holding the result
+type repeatReader struct {
+	text string
+	n    int
+	rest string
+}
+
+func (r *repeatReader) Read(p []byte) (int, error) {
+	if r.rest == "" {
+		if r.n == 0 {
+			return 0, io.EOF
+		}
+		r.n--
+		r.rest = r.text
+	}
+	n := copy(p, r.rest)
+	r.rest = r.rest[n:]
+	return n, nil
+}
+
+func TestInterpret(t *testing.T) {
+	r, w := io.Pipe()
+	statements := make(chan error)
+	m := New()
+	go func() {
+		m.Interpret(r, func(err error) bool {
+			statements <- err
+			return true
+		})
+		close(statements)
+	}()
+
+	// every statement is done before the next line is written
+	for _, tc := range []struct {
+		input string
+		err   error
+	}{
+		{"1 2\n", nil},
+		{"\n", nil},
+		{": f\n 3 foo ;\n", ErrUnknownWord},
+		{"( ok ) : g\n  + ;\n", nil},
+		{"g \\ comment\n", nil},
+	} {
+		// written in a goroutine, as the line may be read only in part
+		go io.WriteString(w, tc.input)
+		select {
+		case err := <-statements:
+			if !errors.Is(err, tc.err) {
+				t.Fatalf("%q: got error %v, want %v", tc.input, err, tc.err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%q: the statement didn't end with its line", tc.input)
+		}
+	}
+	w.Close()
+	if _, ok := <-statements; ok {
+		t.Fatal("statement after the end of the input")
+	}
+	if got, want := m.Stack(), []int{3}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
+
+func TestEvalReaderLargeScript(t *testing.T) {
+	m := New()
+	// about 4 MB of source
+	r := &repeatReader{text: "1 + ( add one )\n", n: 1 << 18}
+	if err := m.EvalReader(io.MultiReader(strings.NewReader("0\n"), r)); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := m.Stack(), []int{1 << 18}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
+
+func TestEvalReaderErrors(t *testing.T) {
+	errRead := errors.New("read failed")
+	m := New()
+	err := m.EvalReader(io.MultiReader(strings.NewReader("1 2\n3"), iotest.ErrReader(errRead)))
+	if !errors.Is(err, errRead) {
+		t.Fatalf("got error %v, want %v", err, errRead)
+	}
+	if got, want := m.Stack(), []int{1, 2}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := m.EvalReaderContext(ctx, strings.NewReader("4")); !errors.Is(err, context.Canceled) {
+		t.Fatalf("got error %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestInclude(t *testing.T) {
+	fsys := fstest.MapFS{
+		"lib/math.fs": {Data: []byte(": sq ( n -- n*n ) dup * ;\n: cube dup sq * ;\n")},
+		"lib/all.fs":  {Data: []byte("require lib/math.fs\n: hypot2 sq swap sq + ;")},
+		"counter.fs":  {Data: []byte("1 +")},
+	}
+	for _, tc := range []struct {
+		input string
+		want  []int
+	}{
+		{"include lib/math.fs 3 cube", []int{27}},
+		{"INCLUDE lib/all.fs 3 4 hypot2", []int{25}},
+		{"0 require counter.fs require counter.fs include counter.fs", []int{2}},
+		{"include lib/math.fs require lib/math.fs 2 sq", []int{4}},
+	} {
+		m := New(WithFS(fsys))
+		if err := m.Eval(tc.input); err != nil {
+			t.Fatalf("%q: %v", tc.input, err)
+		}
+		if got := m.Stack(); !reflect.DeepEqual(got, tc.want) {
+			t.Fatalf("%q: got %v, want %v", tc.input, got, tc.want)
+		}
+	}
+
+	m := New(WithFS(fsys))
+	m.Eval("0 require counter.fs")
+	m.Reset()
+	m.Eval("0 require counter.fs")
+	if got, want := m.Stack(), []int{1}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("Reset didn't forget the included files: got %v, want %v", got, want)
+	}
+}
+
+func TestIncludeErrors(t *testing.T) {
+	fsys := fstest.MapFS{
+		"bad.fs":   {Data: []byte("1 2\n: f\n  foo ;")},
+		"outer.fs": {Data: []byte("\\ includes a broken file\ninclude ./bad.fs")},
+		"a.fs":     {Data: []byte("include b.fs")},
+		"b.fs":     {Data: []byte("1\ninclude a.fs")},
+	}
+	for _, tc := range []struct {
+		input     string
+		err       error
+		file      string
+		line      int
+		column    int
+		statement int
+		token     int
+	}{
+		{"9 include bad.fs", ErrUnknownWord, "bad.fs", 3, 3, 1, 2},
+		{"9 include outer.fs", ErrUnknownWord, "bad.fs", 3, 3, 1, 2},
+		{"include a.fs", ErrIncludeCycle, "b.fs", 2, 1, 1, 0},
+		{"include none.fs", fs.ErrNotExist, "", 1, 1, 0, 0},
+		{": f include bad.fs ;", ErrInterpretOnly, "", 1, 5, 0, 2},
+		{"include", ErrInvalidDefinition, "", 1, 1, 0, 0},
+	} {
+		m := New(WithFS(fsys))
+		err := m.Eval(tc.input)
+		var e *Error
+		if !errors.As(err, &e) || !errors.Is(err, tc.err) {
+			t.Fatalf("%q: got error %v, want %v", tc.input, err, tc.err)
+		}
+		if e.File != tc.file || e.Line != tc.line || e.Column != tc.column {
+			t.Errorf("%q: error at %s:%d:%d, want %s:%d:%d", tc.input, e.File, e.Line, e.Column, tc.file, tc.line, tc.column)
+		}
+		if e.Statement != tc.statement || e.Token != tc.token {
+			t.Errorf("%q: error at statement %d, token %d, want %d, %d", tc.input, e.Statement, e.Token, tc.statement, tc.token)
+		}
+	}
+
+	err := New(WithFS(fsys)).Eval("9 include bad.fs")
+	if want := "bad.fs:3:3: statement 1, token 2: foo: unknown word"; err.Error() != want {
+		t.Errorf("got message %q, want %q", err.Error(), want)
+	}
+	if err := New().Eval("include bad.fs"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("got error %v without a file system, want %v", err, fs.ErrNotExist)
+	}
+}
+
+func TestSee(t *testing.T) {
+	m := New()
+	m.Define("host", func(*Machine) error { return nil })
+	err := m.Eval(`: sq ( n -- n*n ) dup * ;  : hi ." hello  there" s" x" type ;  : nothing ;
+		variable v  7 constant seven  3 value three  marker undo`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct{ name, want string }{
+		{"SQ", ": sq ( n -- n*n ) dup * ;"},
+		{"hi", `: hi ." hello  there" s" x" type ;`},
+		{"nothing", ": nothing ;"},
+		{"v", "VARIABLE v"},
+		{"seven", "7 CONSTANT seven"},
+		{"three", "3 VALUE three"},
+		{"undo", "MARKER undo"},
+		{"host", "host is defined in Go"},
+		{"dup", "DUP is a builtin"},
+		{"if", "IF is a builtin"},
+	} {
+		got, err := m.See(tc.name)
+		if err != nil || got != tc.want {
+			t.Errorf("See(%q) = %q, %v, want %q", tc.name, got, err, tc.want)
+		}
+	}
+	if _, err := m.See("none"); !errors.Is(err, ErrUnknownWord) {
+		t.Errorf("got error %v, want %v", err, ErrUnknownWord)
+	}
+
+	var out bytes.Buffer
+	m = New(WithOutput(&out))
+	if err := m.Eval(": sq dup * ; see sq see +"); err != nil {
+		t.Fatal(err)
+	}
+	if want := ": sq dup * ;\n+ is a builtin\n"; out.String() != want {
+		t.Errorf("SEE printed %q, want %q", out.String(), want)
+	}
+}
+
+func TestDictionary(t *testing.T) {
+	m := New()
+	m.Define("host", func(*Machine) error { return nil })
+	if err := m.Eval(": sq ( n -- n*n ) dup * ; variable v : dup over ;"); err != nil {
+		t.Fatal(err)
+	}
+	defs := m.Dictionary()
+	want := []Definition{
+		{"dup", "colon", "", ": dup over ;"},
+		{"v", "variable", "", "VARIABLE v"},
+		{"sq", "colon", "n -- n*n", ": sq ( n -- n*n ) dup * ;"},
+		{"host", "go", "", "host is defined in Go"},
+		{":", "builtin", "", ": is a builtin"},
+	}
+	if !reflect.DeepEqual(defs[:len(want)], want) {
+		t.Fatalf("got %v, want %v first", defs[:len(want)], want)
+	}
+	for _, d := range defs {
+		if d.Name == "DUP" {
+			t.Fatal("redefined builtin DUP is listed")
+		}
+	}
+	if names := m.Words(); len(names) != len(defs) || names[0] != "dup" {
+		t.Fatalf("Words() doesn't match the dictionary: %v", names)
+	}
+
+	var out bytes.Buffer
+	m = New(WithOutput(&out))
+	if err := m.Eval(": foo ; words"); err != nil {
+		t.Fatal(err)
+	}
+	if want := strings.Join(m.Words(), " ") + "\n"; out.String() != want {
+		t.Fatalf("WORDS printed %q, want %q", out.String(), want)
+	}
+}
+
+func TestForget(t *testing.T) {
+	m := New()
+	if err := m.Eval(": foo 1 ; here : foo 2 ; variable v 10 allot : bar foo ;"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Eval("forget FOO foo here"); err != nil {
+		t.Fatal(err)
+	}
+	s := m.Stack()
+	if s[1] != 1 || s[0] != s[2] {
+		t.Fatalf("got %v, want the first foo and HERE back where it was", s)
+	}
+	if err := m.Eval("bar"); !errors.Is(err, ErrUnknownWord) {
+		t.Fatalf("word defined after the forgotten one still known: %v", err)
+	}
+	if err := m.Eval("forget dup"); !errors.Is(err, ErrUnknownWord) {
+		t.Fatalf("got error %v forgetting a builtin, want %v", err, ErrUnknownWord)
+	}
+	if err := m.Forget("foo"); err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Dictionary()) != len(New().Dictionary()) {
+		t.Fatal("Forget left user defined words")
+	}
+}
+
+func TestMarker(t *testing.T) {
+	fsys := fstest.MapFS{
+		"lib.fs": {Data: []byte(`: greet s" hi" ; variable counter`)},
+	}
+	m := New(WithFS(fsys))
+	if err := m.Eval(": keep 1 ; here marker undo require lib.fs : keep 2 ; 5 allot"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Eval("undo keep here"); err != nil {
+		t.Fatal(err)
+	}
+	if s := m.Stack(); s[1] != 1 || s[0] != s[2] {
+		t.Fatalf("got %v, want the first keep and HERE back where it was", s)
+	}
+	for _, name := range []string{"undo", "greet", "counter"} {
+		if _, err := m.See(name); err == nil {
+			t.Errorf("%s is still defined", name)
+		}
+	}
+	if err := m.Eval("require lib.fs greet nip"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Eval("marker m : f m ;"); !errors.Is(err, ErrInterpretOnly) {
+		t.Fatalf("got error %v using a marker in a definition, want %v", err, ErrInterpretOnly)
+	}
+
+	// undo a failed load from Go
+	m = New()
+	if err := m.Marker("before"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Eval(": half 2 / ; : broken half foo ;"); err == nil {
+		t.Fatal("broken definition was accepted")
+	}
+	if err := m.Eval("before"); err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Dictionary()) != len(New().Dictionary()) {
+		t.Fatal("the marker left user defined words")
+	}
+}
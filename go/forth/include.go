@@ -0,0 +1,40 @@
+package forth
+
+import (
+	"bufio"
+	"context"
+	"io/fs"
+	"path"
+	"slices"
+)
+
+// include evaluates the file at name in the file system of the machine.
+// With once set, a file which has been included before is skipped.
+func (m *Machine) include(ctx context.Context, name string, once bool) error {
+	name = path.Clean(name)
+	if _, ok := m.included[name]; ok && once {
+		return nil
+	}
+	if slices.Contains(m.including, name) {
+		return ErrIncludeCycle
+	}
+	if m.fsys == nil {
+		return &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	f, err := m.fsys.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if _, ok := m.included[name]; !ok {
+		m.included[name] = len(m.included)
+	}
+	m.including = append(m.including, name)
+	defer func() { m.including = m.including[:len(m.including)-1] }()
+	err = m.evalSource(ctx, newScanner(bufio.NewReader(f)), true)
+	if e, ok := err.(*Error); ok && e.File == "" {
+		e.File = name
+	}
+	return err
+}
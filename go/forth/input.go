@@ -0,0 +1,61 @@
+package forth
+
+import (
+	"io"
+	"strings"
+)
+
+// key reads a character from the input and pushes its code.
+// It fails with io.EOF at the end of the input.
+func (m *Machine) key() error {
+	r, _, err := m.in.ReadRune()
+	if err != nil {
+		return err
+	}
+	m.valueStack.push(m.wrap(int(r)))
+	return nil
+}
+
+// keyQuestion pushes true if a character can be read from the input.
+// It waits for input, so it is false only at the end of the input.
+func (m *Machine) keyQuestion() error {
+	_, err := m.in.Peek(1)
+	if err != nil && err != io.EOF {
+		return err
+	}
+	m.valueStack.push(flag(err == nil))
+	return nil
+}
+
+// accept reads a line of the input into the buffer given by its address
+// and size, and pushes the number of characters stored. The rest of a line
+// longer than the buffer is dropped, and so is the line end.
+func (m *Machine) accept() error {
+	size, err := m.valueStack.pop()
+	if err != nil {
+		return err
+	}
+	addr, err := m.valueStack.pop()
+	if err != nil {
+		return err
+	}
+	if size < 0 || !m.valid(addr, size) {
+		return ErrInvalidAddress
+	}
+
+	line, err := m.in.ReadString('\n')
+	if err != nil && err != io.EOF {
+		return err
+	}
+	line = strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r")
+	n := 0
+	for _, r := range line {
+		if n == size {
+			break
+		}
+		m.mem[addr+n] = m.wrap(int(r))
+		n++
+	}
+	m.valueStack.push(n)
+	return nil
+}
@@ -0,0 +1,341 @@
+package forth
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// states of the outer interpreter
+const (
+	interpreting = iota
+	naming       // after ":", waiting for the name of the new word
+	compiling    // inside a definition
+)
+
+// ending tells where a statement ends, besides the end of the source
+type ending uint8
+
+const (
+	sourceEnd    ending = iota // only at the end of the source
+	lineEnd                    // at a line end outside of a definition, once it has a token
+	everyLineEnd               // at any line end outside of a definition
+)
+
+// evalStatement interprets a statement read by sc, which ends as end
+// says. Definitions ": name ... ;" may appear anywhere in it; their tokens
+// are compiled into a new word, while the other tokens are compiled and
+// run as soon as a definition starts, a token fails to compile or the
+// statement ends.
+func (m *Machine) evalStatement(ctx context.Context, sc *scanner, end ending) (err error) {
+	var items []string    // the tokens read so far
+	var starts []position // where they start in the source
+	defer func() {
+		if e, ok := err.(*Error); ok && e.File == "" && e.Token < len(starts) {
+			e.Line, e.Column = starts[e.Token].line, starts[e.Token].col
+		}
+	}()
+	add := func(t token) int {
+		items = append(items, t.text)
+		starts = append(starts, t.start)
+		return len(items) - 1
+	}
+
+	state := interpreting
+	interp := &compiler{m: m}
+	pending := false // if interp has code besides literals
+	var def *compiler
+	name := 0       // index of the name of the word being defined
+	effect := ""    // stack effect comment of the word being defined
+	var before mark // the dictionary before the word being defined
+
+	sc.newline = false
+	for {
+		if state == interpreting && (end == lineEnd && len(items) > 0 || end == everyLineEnd) &&
+			sc.endOfLine() {
+			break
+		}
+		t, ok := sc.word()
+		if !ok {
+			break
+		}
+		if t.text == `\` {
+			sc.skipLine()
+			continue
+		}
+		index := add(t)
+		item := t.text
+		var err error
+		switch {
+		case item == "(":
+			index = add(sc.text(')'))
+			if state == compiling && index == name+2 {
+				effect = strings.TrimSpace(items[index])
+			}
+		case stringWords[strings.ToUpper(item)] && state == compiling:
+			index = add(sc.text('"'))
+			if item == `."` {
+				def.addText(items[index], index)
+			} else {
+				err = def.addString(items[index], strings.ToUpper(item) == `C"`, index)
+			}
+		case stringWords[strings.ToUpper(item)] && state == interpreting:
+			// as in Forth, interpreted strings are handled when they are
+			// read, going to the output or a transient buffer
+			if err := m.runCode(ctx, items, interp); err != nil {
+				return err
+			}
+			interp, pending = &compiler{m: m}, false
+			t := add(sc.text('"'))
+			if item == `."` {
+				err = m.write(items[t])
+			} else {
+				index = t
+				err = interp.addString(items[index], strings.ToUpper(item) == `C"`, index)
+			}
+		case state == interpreting && item == ":":
+			if err := m.runCode(ctx, items, interp); err != nil {
+				return err
+			}
+			interp, pending = &compiler{m: m}, false
+			before = m.mark()
+			state = naming
+		case state == interpreting && item == ";":
+			err = ErrCompileOnly
+		case state == interpreting && parsingWords[strings.ToUpper(item)]:
+			if err := m.runCode(ctx, items, interp); err != nil {
+				return err
+			}
+			interp, pending = &compiler{m: m}, false
+			t, ok := sc.word()
+			if !ok {
+				err = fmt.Errorf("%w: missing name", ErrInvalidDefinition)
+				break
+			}
+			if word := strings.ToUpper(item); word == "INCLUDE" || word == "REQUIRE" {
+				err = m.include(ctx, t.text, word == "REQUIRE")
+			} else {
+				err = m.define(word, t.text)
+			}
+			if err == nil {
+				add(t)
+			}
+		case state == compiling && strings.ToUpper(item) == "TO":
+			t, ok := sc.word()
+			if !ok {
+				err = fmt.Errorf("%w: missing name", ErrInvalidDefinition)
+				break
+			}
+			if err = def.addTo(t.text, index+1); err == nil {
+				add(t)
+			}
+		case state == compiling && parsingWords[strings.ToUpper(item)]:
+			err = ErrInterpretOnly
+		case state == naming:
+			if err = checkName(item); err != nil {
+				break
+			}
+			name, effect = index, ""
+			def = &compiler{m: m, definition: true}
+			state = compiling
+		case state == compiling && item == ":":
+			err = fmt.Errorf("%w: nested definition", ErrInvalidDefinition)
+		case state == compiling && item == ";":
+			if err = def.finish(); err != nil {
+				break
+			}
+			w := userWord{name: items[name], code: def.code, effect: effect,
+				source: sourceText(items[name+1 : index]), mark: before}
+			if err := m.addWord(w); err != nil {
+				return newError(items, name, m.valueStack, err)
+			}
+			state = interpreting
+		case state == compiling:
+			err = def.add(item, index)
+		case state == interpreting && m.isMarker(item):
+			if err := m.runCode(ctx, items, interp); err != nil {
+				return err
+			}
+			interp, pending = &compiler{m: m}, false
+			err = m.Forget(item)
+		default:
+			// numbers are read in the BASE set by the code before them,
+			// so that code runs first. Its steps count for the statement.
+			if m.known(strings.ToUpper(item)) {
+				pending = true
+			} else if pending {
+				if err := m.runCode(ctx, items, interp); err != nil {
+					return err
+				}
+				interp, pending = &compiler{m: m}, false
+			}
+			err = interp.add(item, index)
+		}
+		if e, ok := err.(*Error); ok {
+			// failed in an included file, which e locates
+			return e
+		}
+		if err != nil {
+			// as if the tokens were run one at a time, those before
+			// the failing one have been run
+			if err := m.runCode(ctx, items, interp); err != nil {
+				return err
+			}
+			return newError(items, index, m.valueStack, err)
+		}
+	}
+	if sc.err != nil && sc.err != io.EOF {
+		// the last token may be cut short, so nothing is run
+		return &Error{Token: len(items), Stack: m.Stack(), Err: sc.err}
+	}
+
+	switch state {
+	case naming:
+		return newError(items, len(items)-1, m.valueStack, ErrUnterminatedDefinition)
+	case compiling:
+		return newError(items, name, m.valueStack, ErrUnterminatedDefinition)
+	}
+	return m.runCode(ctx, items, interp)
+}
+
+// parsingWords take the name following them in the statement
+var parsingWords = map[string]bool{
+	"VARIABLE": true,
+	"CONSTANT": true,
+	"VALUE":    true,
+	"TO":       true,
+	"INCLUDE":  true,
+	"REQUIRE":  true,
+	"SEE":      true,
+	"FORGET":   true,
+	"MARKER":   true,
+}
+
+// define runs one of the parsingWords for name
+func (m *Machine) define(word, name string) error {
+	switch word {
+	case "SEE":
+		text, err := m.See(name)
+		if err != nil {
+			return err
+		}
+		return m.write(text + "\n")
+	case "FORGET":
+		return m.Forget(name)
+	case "MARKER":
+		return m.Marker(name)
+	case "TO":
+		addr, err := m.valueAddr(name)
+		if err != nil {
+			return err
+		}
+		v, err := m.valueStack.pop()
+		if err != nil {
+			return err
+		}
+		return m.store(addr, v)
+	}
+
+	if err := checkName(name); err != nil {
+		return err
+	}
+	if m.dictionaryFull() {
+		return ErrDictionaryFull
+	}
+	before := m.mark()
+	switch word {
+	case "VARIABLE":
+		addr, err := m.allot(1)
+		if err != nil {
+			return err
+		}
+		return m.addWord(userWord{name: name, kind: variableWord, param: addr,
+			code: []instr{{opLiteral, addr}}, mark: before})
+	case "CONSTANT":
+		v, err := m.valueStack.pop()
+		if err != nil {
+			return err
+		}
+		return m.addWord(userWord{name: name, kind: constantWord, param: v,
+			code: []instr{{opLiteral, v}}, mark: before})
+	case "VALUE":
+		v, err := m.valueStack.pop()
+		if err != nil {
+			return err
+		}
+		addr, err := m.allot(1)
+		if err != nil {
+			m.valueStack.push(v)
+			return err
+		}
+		m.mem[addr] = v
+		return m.addWord(userWord{name: name, kind: valueWord, param: addr,
+			code: []instr{{opLiteral, addr}, {opBuiltin, wordFetch}}, mark: before})
+	}
+	return nil
+}
+
+// valueAddr returns the address of the value created by VALUE name
+func (m *Machine) valueAddr(name string) (int, error) {
+	w, ok := m.dict[strings.ToUpper(name)]
+	if !ok {
+		return 0, ErrUnknownWord
+	}
+	if m.words[w].kind != valueWord {
+		return 0, fmt.Errorf("%w: %s is not a VALUE", ErrInvalidDefinition, name)
+	}
+	return m.words[w].param, nil
+}
+
+// isMarker reports if item names a word made by MARKER
+func (m *Machine) isMarker(item string) bool {
+	w, ok := m.dict[strings.ToUpper(item)]
+	return ok && m.words[w].kind == markerWord
+}
+
+// checkName checks that name can be the name of a new word
+func checkName(name string) error {
+	if isNumber(name) {
+		return fmt.Errorf("%w: can't redefine numbers", ErrInvalidDefinition)
+	}
+	return nil
+}
+
+// dictionaryFull reports if the dictionary has reached its size limit
+func (m *Machine) dictionaryFull() bool {
+	return m.limits.Words > 0 && len(m.words) >= m.limits.Words
+}
+
+// addWord adds a definition to the dictionary.
+//
+// The body has been compiled before the word is added, so it binds to
+// the meanings in effect then: a redefinition doesn't change the words
+// already using the old one, and the new word may refer to the word it
+// replaces.
+func (m *Machine) addWord(w userWord) error {
+	if m.dictionaryFull() {
+		return ErrDictionaryFull
+	}
+	m.dict[strings.ToUpper(w.name)] = len(m.words)
+	m.words = append(m.words, w)
+	return nil
+}
+
+// runCode runs the interpreted code compiled by c from items
+func (m *Machine) runCode(ctx context.Context, items []string, c *compiler) error {
+	pc, err := m.run(ctx, c.code, c.floats)
+	if err == nil {
+		return nil
+	}
+
+	// frames and loops left by the failed code are of no use anymore
+	m.returnStack = newStack()
+	e := err.(*Error)
+	e.Token = c.pos[pc]
+	if e.Word == "" && len(e.Trace) == 0 {
+		e.Word = items[e.Token]
+	}
+	e.Stack = m.Stack()
+	return e
+}
@@ -0,0 +1,134 @@
+package forth
+
+// Data space is a linear memory of cells. Addresses count cells, so a
+// character takes a whole cell too. Address 0 is never valid, which
+// catches uninitialised pointers. The scratch area comes first, then the
+// system variables and buffers, followed by the space allotted by the
+// program, which ends at HERE.
+const (
+	padAddr    = 1                         // start of the scratch area returned by PAD
+	padSize    = 256                       // cells in the scratch area
+	baseAddr   = padAddr + padSize         // BASE
+	holdAddr   = baseAddr + 1              // start of the hold area of pictured output
+	holdSize   = 2*64 + 2                  // a 128 bit double in binary, and a sign
+	stringAddr = holdAddr + holdSize       // start of the two transient string buffers
+	stringSize = 1 + 255                   // cells in a buffer, a counted string of 255 characters
+	userAddr   = stringAddr + 2*stringSize // start of the space allotted by the program
+	maxMemory  = 1 << 27                   // cells of data space without a memory limit
+)
+
+// fetch returns the cell at addr
+func (m *Machine) fetch(addr int) (int, error) {
+	if !m.valid(addr, 1) {
+		return 0, ErrInvalidAddress
+	}
+	return m.mem[addr], nil
+}
+
+// store sets the cell at addr to v
+func (m *Machine) store(addr, v int) error {
+	if !m.valid(addr, 1) {
+		return ErrInvalidAddress
+	}
+	m.mem[addr] = v
+	return nil
+}
+
+// valid reports if the n cells starting at addr are in data space
+func (m *Machine) valid(addr, n int) bool {
+	return addr > 0 && n >= 0 && addr <= len(m.mem)-n
+}
+
+// allot reserves n cells at the end of data space, or releases them if n
+// is negative, and returns the address of the reserved cells
+func (m *Machine) allot(n int) (int, error) {
+	addr := m.here
+	switch {
+	case n < 0 && m.here+n < userAddr:
+		return 0, ErrInvalidAddress
+	case n > 0 && m.limits.Memory > 0 && n > m.limits.Memory-m.here:
+		return 0, ErrMemoryFull
+	case n > 0 && n > m.maxCell-m.here: // addresses must fit in a cell
+		return 0, ErrMemoryFull
+	case n > 0 && n > maxMemory-m.here: // and the memory in the host
+		return 0, ErrMemoryFull
+	}
+	m.here += n
+	if n < 0 {
+		m.mem = m.mem[:m.here]
+	} else {
+		m.mem = append(m.mem, make([]int, n)...)
+	}
+	return addr, nil
+}
+
+// allotOp implements ALLOT ( n -- )
+func (m *Machine) allotOp() error {
+	n, err := m.valueStack.pop()
+	if err != nil {
+		return err
+	}
+	_, err = m.allot(n)
+	return err
+}
+
+// storeOp implements ! ( x addr -- )
+func (m *Machine) storeOp() error {
+	addr, err := m.valueStack.pop()
+	if err != nil {
+		return err
+	}
+	v, err := m.valueStack.pop()
+	if err != nil {
+		return err
+	}
+	return m.store(addr, v)
+}
+
+// fetchOp implements @ ( addr -- x )
+func (m *Machine) fetchOp() error {
+	addr, err := m.valueStack.pop()
+	if err != nil {
+		return err
+	}
+	v, err := m.fetch(addr)
+	if err != nil {
+		return err
+	}
+	m.valueStack.push(v)
+	return nil
+}
+
+// plusStore implements +! ( n addr -- )
+func (m *Machine) plusStore() error {
+	addr, err := m.valueStack.pop()
+	if err != nil {
+		return err
+	}
+	n, err := m.valueStack.pop()
+	if err != nil {
+		return err
+	}
+	v, err := m.fetch(addr)
+	if err != nil {
+		return err
+	}
+	if v, err = m.cell(add(v, n)); err != nil {
+		return err
+	}
+	return m.store(addr, v)
+}
+
+// comma implements , ( x -- ), storing x in a newly allotted cell
+func (m *Machine) comma() error {
+	v, err := m.valueStack.pop()
+	if err != nil {
+		return err
+	}
+	addr, err := m.allot(1)
+	if err != nil {
+		return err
+	}
+	m.mem[addr] = v
+	return nil
+}
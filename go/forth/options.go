@@ -0,0 +1,75 @@
+package forth
+
+import (
+	"bufio"
+	"io"
+	"io/fs"
+)
+
+// Limits bound the resources a Machine may use, so untrusted scripts
+// can't take the host down. A zero field means no limit.
+type Limits struct {
+	Steps       int // instructions executed by a single Eval, or statement of EvalReader
+	Stack       int // cells on the data stack, and numbers on the float stack
+	ReturnStack int // cells on the return stack, a word call takes two
+	Words       int // definitions in the dictionary
+	Memory      int // cells of data space, 1<<27 at most
+}
+
+// DefaultLimits are the limits of a Machine created without WithLimits
+var DefaultLimits = Limits{
+	Stack:       1 << 16,
+	ReturnStack: 1 << 10,
+	Memory:      1 << 20,
+}
+
+// Option configures a Machine created by New
+type Option func(*Machine)
+
+// WithLimits replaces the DefaultLimits of the machine
+func WithLimits(l Limits) Option {
+	return func(m *Machine) {
+		m.limits = l
+	}
+}
+
+// WithOutput makes the output words write to w instead of discarding
+// their output
+func WithOutput(w io.Writer) Option {
+	return func(m *Machine) {
+		m.out = w
+	}
+}
+
+// WithInput makes the input words read from r.
+// By default there is no input at all.
+func WithInput(r io.Reader) Option {
+	return func(m *Machine) {
+		m.in = bufio.NewReader(r)
+	}
+}
+
+// WithFS makes INCLUDE and REQUIRE read the files of fsys, like
+// os.DirFS(dir). By default there are no files at all.
+func WithFS(fsys fs.FS) Option {
+	return func(m *Machine) {
+		m.fsys = fsys
+	}
+}
+
+// WithCellBits sets the width of a cell to 16, 32 or 64 bits, so results
+// don't depend on the platform. Cells are as wide as an int by default.
+// It panics for other widths, and for widths above the size of an int.
+func WithCellBits(n int) Option {
+	return func(m *Machine) {
+		m.setCellBits(n)
+	}
+}
+
+// WithOverflow selects what arithmetic does with results that don't fit
+// in a cell. The default is OverflowWrap.
+func WithOverflow(o Overflow) Option {
+	return func(m *Machine) {
+		m.overflow = o
+	}
+}
@@ -0,0 +1,70 @@
+package forth
+
+import (
+	"io"
+	"strconv"
+	"strings"
+)
+
+// write writes text to the output of the machine
+func (m *Machine) write(text string) error {
+	_, err := io.WriteString(m.out, text)
+	return err
+}
+
+// dot prints the top of the stack followed by a space
+func (m *Machine) dot() error {
+	n, err := m.valueStack.pop()
+	if err != nil {
+		return err
+	}
+	text, err := m.formatCell(n)
+	if err != nil {
+		return err
+	}
+	return m.write(text + " ")
+}
+
+// emit prints the character whose code is on top of the stack
+func (m *Machine) emit() error {
+	c, err := m.valueStack.pop()
+	if err != nil {
+		return err
+	}
+	return m.write(string(rune(c)))
+}
+
+// spaces prints as many spaces as the top of the stack says
+func (m *Machine) spaces() error {
+	n, err := m.valueStack.pop()
+	if err != nil {
+		return err
+	}
+	// write in chunks, so huge counts don't need huge buffers. Every chunk
+	// is a step, so the limits stop huge counts.
+	for ; n > 0; n -= len(blanks) {
+		if err := m.step(m.ctx); err != nil {
+			return err
+		}
+		if err := m.write(blanks[:min(n, len(blanks))]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var blanks = strings.Repeat(" ", 64)
+
+// dotS prints the stack without changing it, e.g. "<2> 1 2 "
+func (m *Machine) dotS() error {
+	var b strings.Builder
+	b.WriteString("<" + strconv.Itoa(len(m.valueStack.item)) + "> ")
+	for _, v := range m.valueStack.item {
+		text, err := m.formatCell(v)
+		if err != nil {
+			return err
+		}
+		b.WriteString(text + " ")
+	}
+	return m.write(b.String())
+}
@@ -0,0 +1,188 @@
+package forth
+
+import (
+	"io"
+	"unicode"
+	"unicode/utf8"
+)
+
+// position is a place in the source, counting lines and columns from 1.
+// Columns count runes.
+type position struct {
+	line, col int
+}
+
+// token is a word of the source, or the text following one, together
+// with the positions of its first and last rune
+type token struct {
+	text       string
+	start, end position
+}
+
+// scanner splits the source read from r into tokens, one at a time
+type scanner struct {
+	r        io.RuneReader
+	pos      position // of the next rune
+	ahead    rune     // a rune read too many, if hasAhead
+	hasAhead bool
+	delim    rune  // the separator which ended the last word, -1 at the end
+	newline  bool  // if a line ended since the start of the last word
+	err      error // the error which ended the source, io.EOF at its end
+	buf      []byte
+}
+
+// newScanner returns a scanner reading from r
+func newScanner(r io.RuneReader) *scanner {
+	return &scanner{r: r, pos: position{1, 1}}
+}
+
+// isSeparator reports if r separates words: white space, and the odd
+// control characters NUL and DC3
+func isSeparator(r rune) bool {
+	return unicode.IsSpace(r) || r == 0 || r == 0x13
+}
+
+// read returns the next rune, or false at the end of the source
+func (s *scanner) read() (rune, bool) {
+	r := s.ahead
+	if s.hasAhead {
+		s.hasAhead = false
+	} else {
+		if s.err != nil {
+			return 0, false
+		}
+		var err error
+		if r, _, err = s.r.ReadRune(); err != nil {
+			s.err = err
+			return 0, false
+		}
+	}
+	if r == '\n' {
+		s.pos = position{s.pos.line + 1, 1}
+		s.newline = true
+	} else {
+		s.pos.col++
+	}
+	return r, true
+}
+
+// unread puts back r, which read has just returned. It must not be a new line.
+func (s *scanner) unread(r rune) {
+	s.ahead, s.hasAhead = r, true
+	s.pos.col--
+}
+
+// space skips the separators before the next word, and reports if a line
+// ended since the start of the last word
+func (s *scanner) space() bool {
+	for {
+		r, ok := s.read()
+		if !ok {
+			return s.newline
+		}
+		if !isSeparator(r) {
+			s.unread(r)
+			return s.newline
+		}
+	}
+}
+
+// endOfLine skips the separators up to the end of the line, and reports
+// if the line has ended since the start of the last word. It doesn't read
+// beyond the line end, so it doesn't wait for the next line.
+func (s *scanner) endOfLine() bool {
+	for !s.newline {
+		r, ok := s.read()
+		if !ok {
+			return true
+		}
+		if !isSeparator(r) {
+			s.unread(r)
+			return false
+		}
+	}
+	return true
+}
+
+// more reports if anything is left of the source, waiting for it if need be
+func (s *scanner) more() bool {
+	if !s.hasAhead && s.err == nil {
+		// read will count the rune once it is taken
+		var err error
+		if s.ahead, _, err = s.r.ReadRune(); err != nil {
+			s.err = err
+		} else {
+			s.hasAhead = true
+		}
+	}
+	return s.hasAhead
+}
+
+// atEnd reports if only separators are left in the source
+func (s *scanner) atEnd() bool {
+	s.space()
+	return !s.hasAhead
+}
+
+// word returns the next word, or false at the end of the source.
+// The separator ending the word is read as well.
+func (s *scanner) word() (token, bool) {
+	s.space()
+	s.newline = false
+	t := token{start: s.pos}
+	s.buf = s.buf[:0]
+	for {
+		at := s.pos
+		r, ok := s.read()
+		if !ok {
+			s.delim = -1
+			break
+		}
+		if isSeparator(r) {
+			s.delim = r
+			break
+		}
+		t.end = at
+		s.buf = utf8.AppendRune(s.buf, r)
+	}
+	if len(s.buf) == 0 {
+		return token{}, false
+	}
+	t.text = string(s.buf)
+	return t, true
+}
+
+// text returns the text right after the last word up to closing, which
+// is skipped. The separators in it become spaces.
+func (s *scanner) text(closing rune) token {
+	t := token{start: s.pos, end: s.pos}
+	s.buf = s.buf[:0]
+	for s.delim != -1 {
+		at := s.pos
+		r, ok := s.read()
+		if !ok || r == closing {
+			break
+		}
+		if r < utf8.RuneSelf && isSeparator(r) {
+			r = ' '
+		}
+		t.end = at
+		s.buf = utf8.AppendRune(s.buf, r)
+	}
+	t.text = string(s.buf)
+	s.newline = false
+	return t
+}
+
+// skipLine skips the rest of the line of the last word
+func (s *scanner) skipLine() {
+	if s.delim == '\n' || s.delim == -1 {
+		return
+	}
+	for {
+		r, ok := s.read()
+		if !ok || r == '\n' {
+			return
+		}
+	}
+}
@@ -0,0 +1,190 @@
+package forth
+
+import (
+	"math/big"
+	"strings"
+)
+
+// Strings are kept in data space, a character per cell. A string is
+// given by the address of its first character and its length, a counted
+// string by the address of a cell holding the length, followed by the
+// characters.
+
+// stringWords are followed by a text up to a closing quote, which is read
+// as a single token
+var stringWords = map[string]bool{
+	`."`: true,
+	`S"`: true,
+	`C"`: true,
+}
+
+// stringConst stores the text of a literal in a definition as a counted
+// string and returns its address. Literals live as long as the dictionary
+// and equal ones share their storage, so they must not be changed.
+func (m *Machine) stringConst(text string) (int, error) {
+	if addr, ok := m.stringIndex[text]; ok {
+		return addr, nil
+	}
+	runes := []rune(text)
+	addr, err := m.allot(1 + len(runes))
+	if err != nil {
+		return 0, err
+	}
+	m.storeString(addr, runes)
+	m.stringIndex[text] = addr
+	return addr, nil
+}
+
+// transientString stores the text of an interpreted literal as a counted
+// string and returns its address. Like in Forth it doesn't take data
+// space: the two transient buffers are used in turn, so a string lasts
+// until the next but one.
+func (m *Machine) transientString(text string) (int, error) {
+	runes := []rune(text)
+	if 1+len(runes) > stringSize {
+		return 0, ErrStringOverflow
+	}
+	m.transient = 1 - m.transient
+	addr := stringAddr + m.transient*stringSize
+	m.storeString(addr, runes)
+	return addr, nil
+}
+
+// storeString stores runes as a counted string at addr
+func (m *Machine) storeString(addr int, runes []rune) {
+	m.mem[addr] = len(runes)
+	for i, r := range runes {
+		m.mem[addr+1+i] = m.wrap(int(r))
+	}
+}
+
+// typeOp implements TYPE ( addr u -- ), printing a string
+func typeOp(m *Machine, c []int) ([]int, error) {
+	addr, u := c[0], c[1]
+	if !m.valid(addr, u) {
+		return nil, ErrInvalidAddress
+	}
+	var b strings.Builder
+	for _, r := range m.mem[addr : addr+u] {
+		b.WriteRune(rune(r))
+	}
+	return nil, m.write(b.String())
+}
+
+// count implements COUNT ( c-addr -- addr u ), giving the string held by
+// a counted string
+func count(m *Machine, c []int) ([]int, error) {
+	u, err := m.fetch(c[0])
+	if err != nil {
+		return nil, err
+	}
+	return []int{c[0] + 1, u}, nil
+}
+
+// compare implements COMPARE ( addr1 u1 addr2 u2 -- n ), which is -1, 0
+// or 1 as the first string sorts before, equal to or after the second
+func compare(m *Machine, c []int) ([]int, error) {
+	a1, u1, a2, u2 := c[0], c[1], c[2], c[3]
+	if !m.valid(a1, u1) || !m.valid(a2, u2) {
+		return nil, ErrInvalidAddress
+	}
+	for i := 0; i < min(u1, u2); i++ {
+		if ch1, ch2 := m.mem[a1+i], m.mem[a2+i]; ch1 < ch2 {
+			return []int{-1}, nil
+		} else if ch1 > ch2 {
+			return []int{1}, nil
+		}
+	}
+	return []int{sign(u1 - u2)}, nil
+}
+
+// move implements MOVE ( src dst u -- ), copying u cells. The areas may
+// overlap.
+func move(m *Machine, c []int) ([]int, error) {
+	src, dst, u := c[0], c[1], c[2]
+	if !m.valid(src, u) || !m.valid(dst, u) {
+		return nil, ErrInvalidAddress
+	}
+	copy(m.mem[dst:dst+u], m.mem[src:src+u])
+	return nil, nil
+}
+
+// fill implements FILL ( addr u char -- )
+func fill(m *Machine, c []int) ([]int, error) {
+	addr, u, char := c[0], c[1], c[2]
+	if !m.valid(addr, u) {
+		return nil, ErrInvalidAddress
+	}
+	for i := addr; i < addr+u; i++ {
+		m.mem[i] = char
+	}
+	return nil, nil
+}
+
+// Pictured numeric output converts a double into text from right to
+// left, in the hold area.
+
+// digits are the digits of all the number bases
+const digits = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+
+// lessNumberSign implements <# ( -- ), starting a conversion
+func (m *Machine) lessNumberSign() error {
+	m.hold = holdAddr + holdSize
+	return nil
+}
+
+// holdChar adds char in front of the text being converted
+func (m *Machine) holdChar(char int) error {
+	if m.hold <= holdAddr {
+		return ErrHoldOverflow
+	}
+	m.hold--
+	m.mem[m.hold] = char
+	return nil
+}
+
+// hold implements HOLD ( char -- )
+func hold(m *Machine, c []int) ([]int, error) {
+	return nil, m.holdChar(c[0])
+}
+
+// signOp implements SIGN ( n -- ), adding a minus sign if n is negative
+func signOp(m *Machine, c []int) ([]int, error) {
+	if c[0] < 0 {
+		return nil, m.holdChar('-')
+	}
+	return nil, nil
+}
+
+// numberSign implements # ( ud -- ud' ), converting the lowest digit
+func numberSign(m *Machine, c []int) ([]int, error) {
+	base, err := m.base()
+	if err != nil {
+		return nil, err
+	}
+	ud := m.udouble(c[0], c[1])
+	q, r := ud.QuoRem(ud, m.ubig(base), new(big.Int))
+	if err := m.holdChar(int(digits[r.Int64()])); err != nil {
+		return nil, err
+	}
+	return m.cells(q, 2), nil
+}
+
+// numberSignS implements #S ( ud -- 0 0 ), converting all the digits,
+// at least one
+func numberSignS(m *Machine, c []int) ([]int, error) {
+	for {
+		var err error
+		if c, err = numberSign(m, c); err != nil {
+			return nil, err
+		}
+		if c[0] == 0 && c[1] == 0 {
+			return c, nil
+		}
+	}
+}
+
+// numberSignGreater implements #> ( xd -- addr u ), ending a conversion
+func numberSignGreater(m *Machine, c []int) ([]int, error) {
+	return []int{m.hold, holdAddr + holdSize - m.hold}, nil
+}
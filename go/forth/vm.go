@@ -0,0 +1,266 @@
+package forth
+
+import "context"
+
+// checkInterval is the number of steps between two checks
+// whether the context of the evaluation is done
+const checkInterval = 1 << 10
+
+// run executes compiled code, which has its float literals in floats.
+// Calling a user defined word pushes a return frame on the return stack:
+// the calling word (-1 for code itself) and the position of the call. On failure run also returns the position in code
+// of the failing instruction, or of the call which led to it.
+//
+// run also enforces the step and stack limits of the machine. Steps are
+// counted in m.steps, for the whole statement being evaluated.
+func (m *Machine) run(ctx context.Context, code []instr, floats []float64) (int, error) {
+	base := len(m.returnStack.item)
+	word, cur := -1, code
+	top := 0 // position in code of the call being run, if any
+
+	// the user defined words being run, outermost first
+	var calls []int
+
+	// failed adds the call trace to e and returns it together
+	// with its position in code
+	failed := func(pc int, e *Error) (int, error) {
+		for _, w := range calls {
+			e.Trace = append(e.Trace, m.words[w].name)
+		}
+		if word != -1 {
+			// the return stack may have been changed by the words
+			pc = top
+		}
+		return pc, e
+	}
+
+	for pc := 0; ; pc++ {
+		if pc == len(cur) {
+			if word == -1 {
+				return 0, nil
+			}
+			// return to the caller
+			caller, at, err := m.popFrame(base)
+			if err == nil && caller == -1 && at != top {
+				err = ErrReturnStackImbalance
+			}
+			if err != nil {
+				return failed(0, &Error{Word: ";", Err: err})
+			}
+			calls = calls[:len(calls)-1]
+			word, pc = caller, at
+			cur = code
+			if word != -1 {
+				cur = m.words[word].code
+			}
+			continue
+		}
+
+		// steps are counted as instructions start, and the stacks checked
+		// after they grow, so a failure always points at an instruction
+		if err := m.step(ctx); err != nil {
+			return failed(pc, &Error{Err: err})
+		}
+
+		in := cur[pc]
+		switch in.op {
+		case opCall:
+			if err := m.rpush(word, pc); err != nil {
+				return failed(pc, &Error{Word: m.words[in.arg].name, Err: err})
+			}
+			if word == -1 {
+				top = pc
+			}
+			calls = append(calls, in.arg)
+			word, cur = in.arg, m.words[in.arg].code
+			pc = -1
+		case opBranch:
+			pc = in.arg - 1
+		case opBranchZero:
+			f, err := m.valueStack.pop()
+			if err != nil {
+				return failed(pc, &Error{Word: "?BRANCH", Err: err})
+			}
+			if f == flagFalse {
+				pc = in.arg - 1
+			}
+		case opDo:
+			if err := m.do(); err != nil {
+				return failed(pc, &Error{Word: "DO", Err: err})
+			}
+		case opLoop, opPlusLoop:
+			step := 1
+			if in.op == opPlusLoop {
+				var err error
+				if step, err = m.valueStack.pop(); err != nil {
+					return failed(pc, &Error{Word: "+LOOP", Err: err})
+				}
+			}
+			more, err := m.nextIteration(base, step)
+			if err != nil {
+				return failed(pc, &Error{Word: "LOOP", Err: err})
+			}
+			if more {
+				pc = in.arg - 1
+			}
+		default:
+			if in.op == opFLiteral && word == -1 {
+				m.fpush(floats[in.arg])
+			} else if err := m.exec(in, base); err != nil {
+				return failed(pc, err.(*Error))
+			}
+			if m.limits.Stack > 0 && max(len(m.valueStack.item), len(m.floatStack)) > m.limits.Stack {
+				return failed(pc, &Error{Err: ErrStackOverflow})
+			}
+		}
+	}
+}
+
+// step counts a step of the statement being evaluated. It fails once
+// the step limit is exceeded, or ctx is done.
+func (m *Machine) step(ctx context.Context) error {
+	m.steps++
+	if m.limits.Steps > 0 && m.steps > m.limits.Steps {
+		return ErrStepLimit
+	}
+	if m.steps%checkInterval == 0 {
+		return ctx.Err()
+	}
+	return nil
+}
+
+// exec runs a single instruction which doesn't change the flow of control.
+// Failures are returned as *Error naming the failing word.
+func (m *Machine) exec(in instr, base int) error {
+	switch in.op {
+	case opLiteral:
+		m.valueStack.push(in.arg)
+	case opBuiltin:
+		if err := builtins[in.arg].fn(m); err != nil {
+			return &Error{Word: builtins[in.arg].name, Err: err}
+		}
+	case opI:
+		if err := m.loopIndex(base, 0); err != nil {
+			return &Error{Word: "I", Err: err}
+		}
+	case opJ:
+		if err := m.loopIndex(base, 1); err != nil {
+			return &Error{Word: "J", Err: err}
+		}
+	case opToR:
+		v, err := m.valueStack.pop()
+		if err == nil {
+			err = m.rpush(v)
+		}
+		if err != nil {
+			return &Error{Word: ">R", Err: err}
+		}
+	case opFromR:
+		v, err := m.rpop(base)
+		if err != nil {
+			return &Error{Word: "R>", Err: err}
+		}
+		m.valueStack.push(v)
+	case opGo:
+		w := &m.words[in.arg]
+		if err := w.fn(m); err != nil {
+			return &Error{Word: w.name, Err: err}
+		}
+	case opFLiteral:
+		m.fpush(m.floats[in.arg])
+	case opPrint:
+		if err := m.write(m.texts[in.arg]); err != nil {
+			return &Error{Word: `."`, Err: err}
+		}
+	case opRFetch:
+		if len(m.returnStack.item) <= base {
+			return &Error{Word: "R@", Err: ErrReturnStackUnderflow}
+		}
+		m.valueStack.push(m.returnStack.item[len(m.returnStack.item)-1])
+	}
+	return nil
+}
+
+// rpush pushes values on the return stack, unless it would grow
+// over its limit
+func (m *Machine) rpush(values ...int) error {
+	if max := m.limits.ReturnStack; max > 0 && len(m.returnStack.item)+len(values) > max {
+		return ErrReturnStackOverflow
+	}
+	for _, v := range values {
+		m.returnStack.push(v)
+	}
+	return nil
+}
+
+// rpop pops a value from the return stack. Values below base belong
+// to the caller of run and are out of reach.
+func (m *Machine) rpop(base int) (int, error) {
+	if len(m.returnStack.item) <= base {
+		return 0, ErrReturnStackUnderflow
+	}
+	return m.returnStack.pop()
+}
+
+// popFrame pops a return frame, checking it is one. Code moving values
+// between the stacks in an unbalanced way would otherwise make the
+// machine continue in an arbitrary place.
+func (m *Machine) popFrame(base int) (word, pc int, err error) {
+	if pc, err = m.rpop(base); err != nil {
+		return 0, 0, ErrReturnStackImbalance
+	}
+	if word, err = m.rpop(base); err != nil {
+		return 0, 0, ErrReturnStackImbalance
+	}
+	if word < -1 || word >= len(m.words) || pc < 0 {
+		return 0, 0, ErrReturnStackImbalance
+	}
+	if word >= 0 && pc >= len(m.words[word].code) {
+		return 0, 0, ErrReturnStackImbalance
+	}
+	return word, pc, nil
+}
+
+// do moves the loop limit and start index from the stack
+// to the return stack
+func (m *Machine) do() error {
+	index, err := m.valueStack.pop()
+	if err != nil {
+		return err
+	}
+	limit, err := m.valueStack.pop()
+	if err != nil {
+		return err
+	}
+	return m.rpush(limit, index)
+}
+
+// loopIndex copies the index of the loop nested depth levels
+// above the innermost one to the stack
+func (m *Machine) loopIndex(base, depth int) error {
+	// every loop keeps its limit and its index on the return stack
+	pos := len(m.returnStack.item) - 1 - 2*depth
+	if pos < base {
+		return ErrReturnStackUnderflow
+	}
+	m.valueStack.push(m.returnStack.item[pos])
+	return nil
+}
+
+// nextIteration adds step to the innermost loop index and reports if the
+// loop goes on. A loop ends when its index crosses the boundary between
+// limit-1 and limit; its parameters are then dropped from the return stack.
+func (m *Machine) nextIteration(base, step int) (bool, error) {
+	rs := m.returnStack.item
+	if len(rs)-2 < base {
+		return false, ErrReturnStackUnderflow
+	}
+	limit, index := rs[len(rs)-2], rs[len(rs)-1]
+	before, after := index-limit, index+step-limit
+	if (before < 0) != (after < 0) {
+		m.returnStack.item = rs[:len(rs)-2]
+		return false, nil
+	}
+	rs[len(rs)-1] = index + step
+	return true, nil
+}
@@ -0,0 +1,261 @@
+package forth
+
+import "math"
+
+// builtin is a word implemented in Go
+type builtin struct {
+	name string
+	fn   func(m *Machine) error
+}
+
+// builtins are the words every machine knows, besides the ones handled
+// by the compiler. Compiled code refers to them by their position here.
+var builtins = []builtin{
+	// arithmetic
+	{"+", arithWord(add)},
+	{"-", arithWord(sub)},
+	{"*", arithWord(mul)},
+	{"/", (*Machine).divide},
+	{"MOD", binaryWord(modulo)},
+	{"/MOD", (*Machine).divMod},
+	{"NEGATE", unaryArithWord(negate)},
+	{"ABS", unaryArithWord(absolute)},
+	{"MIN", binaryWord(func(a, b int) (int, error) { return min(a, b), nil })},
+	{"MAX", binaryWord(func(a, b int) (int, error) { return max(a, b), nil })},
+	{"1+", unaryArithWord(func(a int) (int, int) { return add(a, 1) })},
+	{"1-", unaryArithWord(func(a int) (int, int) { return sub(a, 1) })},
+	{"2*", (*Machine).twoStar},
+	{"2/", unaryWord(func(a int) int { return a >> 1 })},
+	{"LSHIFT", (*Machine).lshift},
+	{"RSHIFT", (*Machine).rshift},
+
+	// double and mixed precision arithmetic
+	{"D+", cellsWord(4, dPlus)},
+	{"D-", cellsWord(4, dMinus)},
+	{"DNEGATE", cellsWord(2, dNegate)},
+	{"M*", cellsWord(2, mStar)},
+	{"UM*", cellsWord(2, umStar)},
+	{"UM/MOD", cellsWord(3, umSlashMod)},
+	{"*/", cellsWord(3, starSlash)},
+	{"*/MOD", cellsWord(3, starSlashMod)},
+
+	// floating point
+	{"F+", floatWord(func(a, b float64) float64 { return a + b })},
+	{"F-", floatWord(func(a, b float64) float64 { return a - b })},
+	{"F*", floatWord(func(a, b float64) float64 { return a * b })},
+	{"F/", floatWord(func(a, b float64) float64 { return a / b })},
+	{"FSQRT", unaryFloatWord(math.Sqrt)},
+	{"FDUP", (*Machine).fDup},
+	{"FDROP", (*Machine).fDrop},
+	{"FSWAP", (*Machine).fSwap},
+	{"F<", (*Machine).fLess},
+	{"S>F", (*Machine).sToF},
+	{"F>S", (*Machine).fToS},
+
+	// stack manipulation
+	{"DUP", stackWord(dup)},
+	{"DROP", stackWord(drop)},
+	{"SWAP", stackWord(swap)},
+	{"OVER", stackWord(over)},
+	{"ROT", shuffle(3, 1, 2, 0)},
+	{"-ROT", shuffle(3, 2, 0, 1)},
+	{"NIP", shuffle(2, 1)},
+	{"TUCK", shuffle(2, 1, 0, 1)},
+	{"2DUP", shuffle(2, 0, 1, 0, 1)},
+	{"2DROP", shuffle(2)},
+	{"2SWAP", shuffle(4, 2, 3, 0, 1)},
+	{"2OVER", shuffle(4, 0, 1, 2, 3, 0, 1)},
+	{"PICK", stackWord(pick)},
+	{"ROLL", stackWord(roll)},
+	{"?DUP", stackWord(questionDup)},
+	{"DEPTH", stackWord(depth)},
+
+	// comparison and logic
+	{"=", binaryWord(func(a, b int) (int, error) { return flag(a == b), nil })},
+	{"<", binaryWord(func(a, b int) (int, error) { return flag(a < b), nil })},
+	{">", binaryWord(func(a, b int) (int, error) { return flag(a > b), nil })},
+	{"<>", binaryWord(func(a, b int) (int, error) { return flag(a != b), nil })},
+	{"0=", unaryWord(func(a int) int { return flag(a == 0) })},
+	{"AND", binaryWord(func(a, b int) (int, error) { return a & b, nil })},
+	{"OR", binaryWord(func(a, b int) (int, error) { return a | b, nil })},
+	{"INVERT", unaryWord(func(a int) int { return ^a })},
+
+	// output
+	{".", (*Machine).dot},
+	{"EMIT", (*Machine).emit},
+	{"CR", func(m *Machine) error { return m.write("\n") }},
+	{"SPACE", func(m *Machine) error { return m.write(" ") }},
+	{"SPACES", (*Machine).spaces},
+	{".S", (*Machine).dotS},
+	{"D.", (*Machine).dDot},
+
+	// number base
+	{"BASE", func(m *Machine) error { m.valueStack.push(baseAddr); return nil }},
+	{"DECIMAL", setBase(10)},
+	{"HEX", setBase(16)},
+	{"OCTAL", setBase(8)},
+	{"BINARY", setBase(2)},
+	{"F.", (*Machine).fDot},
+
+	// input
+	{"KEY", (*Machine).key},
+	{"KEY?", (*Machine).keyQuestion},
+	{"ACCEPT", (*Machine).accept},
+
+	// memory
+	{"PAD", func(m *Machine) error { m.valueStack.push(padAddr); return nil }},
+	{"!", (*Machine).storeOp},
+	{"@", (*Machine).fetchOp},
+	{"+!", (*Machine).plusStore},
+	{",", (*Machine).comma},
+	{"ALLOT", (*Machine).allotOp},
+	{"CELLS", unaryWord(func(a int) int { return a })}, // an address unit is a cell
+	{"HERE", func(m *Machine) error { m.valueStack.push(m.here); return nil }},
+	{"WORDS", nil},             // set by init, as it lists the builtins
+	{"C!", (*Machine).storeOp}, // a character takes a cell
+	{"C@", (*Machine).fetchOp},
+
+	// strings
+	{"TYPE", cellsWord(2, typeOp)},
+	{"COUNT", cellsWord(1, count)},
+	{"COMPARE", cellsWord(4, compare)},
+	{"MOVE", cellsWord(3, move)},
+	{"FILL", cellsWord(3, fill)},
+
+	// pictured numeric output
+	{"<#", (*Machine).lessNumberSign},
+	{"#", cellsWord(2, numberSign)},
+	{"#S", cellsWord(2, numberSignS)},
+	{"#>", cellsWord(2, numberSignGreater)},
+	{"HOLD", cellsWord(1, hold)},
+	{"SIGN", cellsWord(1, signOp)},
+}
+
+// builtinIndex maps the names of the builtins to their position in builtins
+var builtinIndex = func() map[string]int {
+	index := make(map[string]int, len(builtins))
+	for i, b := range builtins {
+		index[b.name] = i
+	}
+	return index
+}()
+
+// builtins the compiler generates code for
+var (
+	wordFetch = builtinIndex["@"]
+	wordStore = builtinIndex["!"]
+)
+
+// binaryWord makes a word out of a binary operation
+func binaryWord(op func(a, b int) (int, error)) func(*Machine) error {
+	return func(m *Machine) error {
+		return binaryOp(m.valueStack, op)
+	}
+}
+
+// unaryWord makes a word out of a unary operation
+func unaryWord(op func(a int) int) func(*Machine) error {
+	return func(m *Machine) error {
+		return unaryOp(m.valueStack, op)
+	}
+}
+
+// stackWord makes a word out of an operation on the stack
+func stackWord(op func(s *stack) error) func(*Machine) error {
+	return func(m *Machine) error {
+		return op(m.valueStack)
+	}
+}
+
+// shuffle makes a word rearranging the top n items of the stack. They are
+// replaced by the items at the positions in order, where 0 is the deepest
+// of the n items.
+func shuffle(n int, order ...int) func(*Machine) error {
+	return func(m *Machine) error {
+		s := m.valueStack
+		if len(s.item) < n {
+			return ErrStackUnderflow
+		}
+		var top [4]int
+		copy(top[:], s.item[len(s.item)-n:])
+		s.item = s.item[:len(s.item)-n]
+		for _, i := range order {
+			s.push(top[i])
+		}
+		return nil
+	}
+}
+
+// cellsWord makes a word replacing the top n cells of the stack with the
+// results of op. op gets the n cells, deepest first. The stack is left
+// unchanged when it fails.
+func cellsWord(n int, op func(m *Machine, c []int) ([]int, error)) func(*Machine) error {
+	return func(m *Machine) error {
+		s := m.valueStack
+		if len(s.item) < n {
+			return ErrStackUnderflow
+		}
+		res, err := op(m, s.item[len(s.item)-n:])
+		if err != nil {
+			return err
+		}
+		s.item = append(s.item[:len(s.item)-n], res...)
+		return nil
+	}
+}
+
+// modulo is the remainder of divide, it has the sign of a
+func modulo(a, b int) (int, error) {
+	if b == 0 {
+		return 0, ErrDivisionByZero
+	}
+	return a % b, nil
+}
+
+// pick copies the u-th item below u to the top of the stack
+func pick(s *stack) error {
+	u, err := s.pop()
+	if err != nil {
+		return err
+	}
+	if u < 0 || u >= len(s.item) {
+		s.push(u)
+		return ErrStackUnderflow
+	}
+	s.push(s.item[len(s.item)-1-u])
+	return nil
+}
+
+// roll moves the u-th item below u to the top of the stack
+func roll(s *stack) error {
+	u, err := s.pop()
+	if err != nil {
+		return err
+	}
+	if u < 0 || u >= len(s.item) {
+		s.push(u)
+		return ErrStackUnderflow
+	}
+	pos := len(s.item) - 1 - u
+	v := s.item[pos]
+	copy(s.item[pos:], s.item[pos+1:])
+	s.item[len(s.item)-1] = v
+	return nil
+}
+
+// questionDup duplicates the top of the stack unless it is zero
+func questionDup(s *stack) error {
+	if len(s.item) == 0 {
+		return ErrStackUnderflow
+	}
+	if v := s.item[len(s.item)-1]; v != 0 {
+		s.push(v)
+	}
+	return nil
+}
+
+// depth pushes the number of items on the stack
+func depth(s *stack) error {
+	s.push(len(s.item))
+	return nil
+}